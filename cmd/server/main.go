@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
 	"log"
@@ -13,6 +14,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -27,7 +29,12 @@ func main() {
 		log.Fatal(err)
 	}
 
-	audioService, err := audio.NewServiceAudio(cfg.Youtube.Token, cfg.Youtube.Limit)
+	providers, err := newProviders(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	audioService, err := audio.NewServiceAudio(providers...)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -38,12 +45,13 @@ func main() {
 	roomService := room.NewServiceRoom(cleanupInterval, emptyRoomTTL)
 
 	httpHandler := http_transport.NewHandler(audioService, roomService)
-	wsHandler := ws_transport.NewWSHandler(roomService)
+	wsHandler := ws_transport.NewWSHandler(roomService, audioService)
 
 	a := api.NewAPI(
 		api.Deps{
 			WsHandler:   wsHandler,
 			HttpHandler: httpHandler,
+			AdminToken:  cfg.Rest.AdminToken,
 		})
 
 	srv := &http.Server{
@@ -68,3 +76,22 @@ func main() {
 
 	log.Println("Shutting down gracefully...")
 }
+
+// newProviders создает провайдеров музыки из PROVIDERS в том же порядке
+func newProviders(cfg config.Config) ([]audio.Provider, error) {
+	providers := make([]audio.Provider, 0, len(cfg.Providers))
+	for _, name := range cfg.Providers {
+		switch strings.TrimSpace(name) {
+		case audio.ProviderYouTube:
+			youtube, err := audio.NewYouTube(cfg.Youtube)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, youtube)
+		default:
+			return nil, fmt.Errorf("unknown music provider %q", name)
+		}
+	}
+
+	return providers, nil
+}
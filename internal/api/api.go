@@ -1,9 +1,11 @@
 package api
 
 import (
+	"crypto/subtle"
 	http_transport "mrs/internal/transport/http"
 	ws_transport "mrs/internal/transport/ws"
 	"net/http"
+	"strings"
 )
 
 type API struct {
@@ -13,27 +15,81 @@ type API struct {
 type Deps struct {
 	HttpHandler *http_transport.Handler
 	WsHandler   *ws_transport.WSHandler
+	AdminToken  string // токен для /admin/*, пустой — админские ручки выключены
 }
 
 func NewAPI(deps Deps) *API {
 	apiMux := http.NewServeMux()
 
 	apiMux.HandleFunc("/videos", Method(http.MethodGet, deps.HttpHandler.GetListVideo))
+	apiMux.HandleFunc("/admin/cache", Method(http.MethodGet, Admin(deps.AdminToken, deps.HttpHandler.GetCacheStats)))
+	apiMux.HandleFunc("/admin/quota", Method(http.MethodGet, Admin(deps.AdminToken, deps.HttpHandler.GetQuota)))
 	apiMux.HandleFunc("/rooms", Method(http.MethodPost, deps.HttpHandler.CreateRoom))
 	apiMux.HandleFunc("/rooms/queue", Method(http.MethodPost, deps.HttpHandler.AddVideoInQueue))
+	apiMux.HandleFunc("/rooms/playlist", Method(http.MethodPost, deps.HttpHandler.AddPlaylistInQueue))
 	apiMux.HandleFunc("/rooms/seek", Method(http.MethodPost, deps.HttpHandler.Seek))
+	apiMux.HandleFunc("/rooms/repeat", Method(http.MethodPost, deps.HttpHandler.SetRepeat))
+	apiMux.HandleFunc("/rooms/voteskip", Method(http.MethodPost, deps.HttpHandler.SetVoteSkip))
+	apiMux.HandleFunc("/rooms/role", Method(http.MethodPost, deps.HttpHandler.SetRole))
+	apiMux.HandleFunc("/rooms/fair", Method(http.MethodPost, deps.HttpHandler.SetFairQueue))
+	apiMux.HandleFunc("/rooms/policy", Method(http.MethodPost, deps.HttpHandler.SetPolicy))
+	apiMux.HandleFunc("/rooms/settings", Method(http.MethodPatch, deps.HttpHandler.UpdateSettings))
 	apiMux.HandleFunc("/rooms/info", Method(http.MethodGet, deps.HttpHandler.GetAllRoomsInfo))
+	apiMux.HandleFunc("/rooms/list", Method(http.MethodGet, deps.HttpHandler.ListRooms))
+	apiMux.HandleFunc("/rooms/{id}", Method(http.MethodGet, deps.HttpHandler.GetState))
+	apiMux.HandleFunc("/rooms/{id}/play", Method(http.MethodPost, deps.HttpHandler.Play))
+	apiMux.HandleFunc("/rooms/{id}/pause", Method(http.MethodPost, deps.HttpHandler.Pause))
+	apiMux.HandleFunc("/rooms/{id}/stop", Method(http.MethodPost, deps.HttpHandler.Stop))
+	apiMux.HandleFunc("/rooms/{id}/next", Method(http.MethodPost, deps.HttpHandler.Next))
+	apiMux.HandleFunc("/rooms/{id}/previous", Method(http.MethodPost, deps.HttpHandler.Previous))
+	apiMux.HandleFunc("/rooms/history", Method(http.MethodGet, deps.HttpHandler.GetHistory))
 	apiMux.HandleFunc("/rooms/delete", Method(http.MethodDelete, deps.HttpHandler.DeleteVideoInQueue))
+	apiMux.HandleFunc("/rooms/move", Method(http.MethodPost, deps.HttpHandler.MoveVideoInQueue))
+	apiMux.HandleFunc("/rooms/playnext", Method(http.MethodPost, deps.HttpHandler.PlayNext))
 
 	rootMux := http.NewServeMux()
 
 	rootMux.Handle("/api/v1/", http.StripPrefix("/api/v1", apiMux))
+	rootMux.Handle("/api/v2/", http.StripPrefix("/api/v2", newV2(deps.HttpHandler, deps.AdminToken)))
 
 	rootMux.HandleFunc("/ws/room", deps.WsHandler.RoomWS)
 
 	return &API{mux: rootMux}
 }
 
+// newV2 — маршруты v2: комната и запись очереди в пути, метод в шаблоне.
+// Хендлеры общие с v1, они берут айди из пути, если его нет — из query
+func newV2(h *http_transport.Handler, adminToken string) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /videos", h.GetListVideo)
+	mux.HandleFunc("GET /admin/cache", Admin(adminToken, h.GetCacheStats))
+	mux.HandleFunc("GET /admin/quota", Admin(adminToken, h.GetQuota))
+
+	mux.HandleFunc("POST /rooms", h.CreateRoom)
+	mux.HandleFunc("GET /rooms", h.ListRooms)
+	mux.HandleFunc("GET /rooms/{id}", h.GetState)
+	mux.HandleFunc("PATCH /rooms/{id}/settings", h.UpdateSettings)
+	mux.HandleFunc("GET /rooms/{id}/history", h.GetHistory)
+	mux.HandleFunc("PUT /rooms/{id}/members/{userId}/role", h.SetRole)
+
+	mux.HandleFunc("POST /rooms/{id}/play", h.Play)
+	mux.HandleFunc("POST /rooms/{id}/pause", h.Pause)
+	mux.HandleFunc("POST /rooms/{id}/stop", h.Stop)
+	mux.HandleFunc("POST /rooms/{id}/next", h.Next)
+	mux.HandleFunc("POST /rooms/{id}/previous", h.Previous)
+	mux.HandleFunc("POST /rooms/{id}/seek", h.Seek)
+	mux.HandleFunc("PUT /rooms/{id}/repeat", h.SetRepeat)
+
+	mux.HandleFunc("POST /rooms/{id}/queue", h.AddVideoInQueue)
+	mux.HandleFunc("POST /rooms/{id}/queue/front", h.PlayNext)
+	mux.HandleFunc("POST /rooms/{id}/queue/playlist", h.AddPlaylistInQueue)
+	mux.HandleFunc("DELETE /rooms/{id}/queue/{entryId}", h.DeleteVideoInQueue)
+	mux.HandleFunc("POST /rooms/{id}/queue/{entryId}/move", h.MoveVideoInQueue)
+
+	return mux
+}
+
 func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	a.mux.ServeHTTP(w, r)
 }
@@ -48,3 +104,23 @@ func Method(method string, handler http.HandlerFunc) http.HandlerFunc {
 		handler(w, r)
 	}
 }
+
+// Admin пускает к ручке только с токеном администратора в заголовке Authorization: Bearer {token}.
+// Без настроенного токена ручки нет вовсе
+func Admin(token string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http_transport.WriteJsonError(w, http.StatusNotFound, "admin endpoints are disabled")
+			return
+		}
+
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http_transport.WriteJsonError(w, http.StatusUnauthorized, "admin token required")
+			return
+		}
+
+		handler(w, r)
+	}
+}
@@ -1,13 +1,25 @@
 package config
 
+import "time"
+
 type Config struct {
-	Youtube Youtube
-	Rest    Rest
+	// включенные провайдеры музыки через запятую, первый — провайдер по умолчанию
+	Providers []string `envconfig:"PROVIDERS" default:"youtube"`
+	Youtube   Youtube
+	Rest      Rest
 }
 
 type Youtube struct {
-	Token string `envconfig:"TOKEN"`
-	Limit int64  `envconfig:"LIMIT"`
+	// ключи YouTube Data API через запятую, при отказе YouTube запрос уходит следующему
+	Tokens []string `envconfig:"TOKEN"`
+	Limit  int64    `envconfig:"LIMIT"`
+	// сколько видео максимум импортируется из одного плейлиста
+	PlaylistLimit int `envconfig:"PLAYLIST_LIMIT" default:"200"`
+	// кеш поиска и метаданных видео: сколько записей держать и сколько они живут
+	CacheSize int           `envconfig:"CACHE_SIZE" default:"1000"`
+	CacheTTL  time.Duration `envconfig:"CACHE_TTL" default:"10m"`
+	// дневной бюджет квоты YouTube в единицах, 0 — не ограничивать
+	QuotaBudget int `envconfig:"QUOTA_BUDGET" default:"10000"`
 }
 
 type Rest struct {
@@ -16,4 +28,6 @@ type Rest struct {
 	WriteTimeout      int64  `envconfig:"WRITE_TIMEOUT"`
 	ReadHeaderTimeout int64  `envconfig:"READ_HEADER_TIMEOUT"`
 	IdleTimeout       int64  `envconfig:"IDLE_TIMEOUT"`
+	// токен для /admin/*, пустой — админские ручки выключены
+	AdminToken string `envconfig:"ADMIN_TOKEN"`
 }
@@ -5,37 +5,278 @@ import (
 	"time"
 )
 
+// режимы повтора в комнате
+const (
+	RepeatOff = "off" // очередь играет один раз
+	RepeatOne = "one" // текущий трек перезапускается по окончании
+	RepeatAll = "all" // доигравший трек уходит в конец очереди
+)
+
+// роли в комнате
+const (
+	RoleOwner    = "owner"    // создатель комнаты, может все и назначает диджеев
+	RoleDJ       = "dj"       // управляет воспроизведением и очередью
+	RoleListener = "listener" // слушает, добавляет треки и голосует за пропуск
+)
+
+// сортировка и видимость в каталоге комнат
+const (
+	SortCreated   = "created"   // сначала новые
+	SortListeners = "listeners" // сначала те, где больше слушателей
+
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
 type Video struct {
+	Provider string `json:"provider"` // откуда трек: youtube, ...
 	URL      string `json:"url"`
 	Title    string `json:"title"`
 	Duration int64  `json:"duration"`
 }
 
+// QueueEntry — трек в очереди комнаты со стабильным айди, который выдает сервер
+type QueueEntry struct {
+	ID      uuid.UUID `json:"id"`
+	AddedBy int       `json:"added_by"` // айди подключения, которое добавило трек; 0 — вне комнаты
+	Video
+}
+
+// Policy — правила, по которым комната принимает треки в очередь. Нули — ограничения нет
+type Policy struct {
+	RejectDuplicates bool  `json:"reject_duplicates"`  // не пускать трек, который уже в очереди или играет
+	MaxTrackDuration int64 `json:"max_track_duration"` // максимальная длина трека в секундах
+	MaxQueueDuration int64 `json:"max_queue_duration"` // максимальная суммарная длина очереди в секундах
+	ReplayCooldown   int64 `json:"replay_cooldown"`    // сколько минут трек нельзя ставить повторно после проигрывания
+}
+
+// RoomSettings — настройки комнаты, которые меняет владелец
+type RoomSettings struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	VoteSkip  bool    `json:"vote_skip"`  // next от слушателя — это голос за пропуск
+	VoteShare float64 `json:"vote_share"` // доля подписчиков, которая должна проголосовать
+
+	FairQueue bool `json:"fair_queue"` // очередь чередует треки разных авторов по кругу
+	UserLimit int  `json:"user_limit"` // сколько треков один автор может держать в очереди, 0 — без лимита
+
+	Policy Policy `json:"policy"` // правила приема треков в очередь
+}
+
+// RoomSettingsPatch — частичное изменение настроек: nil-поля не трогаем
+type RoomSettingsPatch struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+
+	VoteSkip  *bool    `json:"vote_skip,omitempty"`
+	VoteShare *float64 `json:"vote_share,omitempty"`
+
+	FairQueue *bool `json:"fair_queue,omitempty"`
+	UserLimit *int  `json:"user_limit,omitempty"`
+
+	Policy *Policy `json:"policy,omitempty"` // правила заменяются целиком
+}
+
+// SearchQuery — запрос поиска видео; PageToken берется из прошлой страницы
+type SearchQuery struct {
+	Provider  string // пусто — провайдер по умолчанию
+	Query     string
+	PageToken string
+	PageSize  int64 // 0 — размер страницы по умолчанию (LIMIT)
+}
+
+// SearchPage — страница результатов поиска
+type SearchPage struct {
+	Videos        []*Video `json:"videos"`
+	NextPageToken string   `json:"next_page_token,omitempty"`
+	PrevPageToken string   `json:"prev_page_token,omitempty"`
+}
+
+// CacheStats — счетчики одного кеша
+type CacheStats struct {
+	Size      int    `json:"size"`
+	Capacity  int    `json:"capacity"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Coalesced uint64 `json:"coalesced"` // запросы, которые дождались чужого похода в YouTube
+}
+
+// CacheReport — состояние кешей сервиса видео
+type CacheReport struct {
+	Searches CacheStats `json:"searches"`
+	Videos   CacheStats `json:"videos"`
+}
+
+// QuotaUsage — сколько раз вызывалась операция YouTube и сколько единиц квоты на нее ушло
+type QuotaUsage struct {
+	Calls int `json:"calls"`
+	Units int `json:"units"`
+}
+
+// KeyQuota — расход дневной квоты одного ключа YouTube, сбрасывается в ResetAt
+type KeyQuota struct {
+	Key        string                `json:"key"`    // последние символы ключа
+	Budget     int                   `json:"budget"` // 0 — без ограничения
+	Used       int                   `json:"used"`
+	Remaining  int                   `json:"remaining"` // при Budget 0 не считается
+	Exhausted  bool                  `json:"exhausted"`
+	Blocked    string                `json:"blocked,omitempty"` // quota_exceeded или key_invalid: YouTube отказал ключу до сброса
+	ResetAt    time.Time             `json:"reset_at"`
+	Operations map[string]QuotaUsage `json:"operations"`
+}
+
+// QuotaReport — расход квоты YouTube в сумме по всем ключам и по каждому ключу
+type QuotaReport struct {
+	Budget     int                   `json:"budget"`
+	Used       int                   `json:"used"`
+	Remaining  int                   `json:"remaining"`
+	Exhausted  bool                  `json:"exhausted"` // квоты нет ни на одном ключе
+	ResetAt    time.Time             `json:"reset_at"`
+	Operations map[string]QuotaUsage `json:"operations"`
+	Keys       []KeyQuota            `json:"keys"`
+}
+
+// Playlist — видео плейлиста YouTube, которые можно поставить в очередь
+type Playlist struct {
+	Videos    []*Video
+	Skipped   int  // закрытые и удаленные видео
+	Truncated bool // в плейлисте больше видео, чем разрешено импортировать
+}
+
+// PlaylistRequest — тело запроса на импорт плейлиста: ссылка или айди
+type PlaylistRequest struct {
+	Provider string `json:"provider,omitempty"` // пусто — провайдер, который узнает ссылку
+	URL      string `json:"url"`
+}
+
+// PlaylistImport — итог импорта плейлиста в очередь
+type PlaylistImport struct {
+	Entries   []*QueueEntry `json:"entries"`
+	Skipped   int           `json:"skipped"`   // закрытые и удаленные видео
+	Rejected  int           `json:"rejected"`  // не прошли правила комнаты
+	Truncated bool          `json:"truncated"` // часть плейлиста не импортирована из-за лимита
+}
+
+// HistoryEntry — трек, который уже играл в комнате, и когда он начал играть
+type HistoryEntry struct {
+	QueueEntry
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Meta — параметры вызова, которые передаются вместе с изменением комнаты
+type Meta struct {
+	Version  uint64 // версия state, которую видел клиент; 0 — без проверки
+	IfMatch  bool   // версия пришла в If-Match: при расхождении — не конфликт, а невыполненное предусловие
+	UserID   int    // айди подписчика комнаты (WS-подключения); 0 — вызов не от подписчика
+	Token    string // секрет владельца комнаты или токен подключения
+	Password string // пароль комнаты, если она им защищена
+}
+
+// CreateRoomRequest — необязательное тело POST /rooms
+type CreateRoomRequest struct {
+	Password string `json:"password,omitempty"` // пустой — комната без пароля
+	Private  bool   `json:"private,omitempty"`  // приватная комната не видна в списке тем, кто в ней не состоит
+
+	RoomSettingsPatch // начальные настройки, не заданные берутся по умолчанию
+}
+
+// Member — подключение к комнате и его роль
+type Member struct {
+	UserID int    `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// Session — первое сообщение по WebSocket: кто ты в этой комнате
+type Session struct {
+	Type   string `json:"type"` // всегда "session"
+	UserID int    `json:"user_id"`
+	Role   string `json:"role"`
+	Token  string `json:"token"` // токен подключения для REST-вызовов от его имени
+}
+
 type ResponseRoom struct {
-	ID uuid.UUID `json:"id"`
+	ID         uuid.UUID `json:"id"`
+	OwnerToken string    `json:"owner_token"` // секрет владельца, показывается один раз
 }
 
 type Command struct {
-	Type string `json:"type"` // "play", "pause", "next"
+	Type  string    `json:"type"`            // "play", "pause", "next", "previous", "repeat", "move", "play_next", "role"
+	Mode  string    `json:"mode,omitempty"`  // режим повтора для "repeat"
+	Entry uuid.UUID `json:"entry"`           // айди трека в очереди для "move"
+	To    int       `json:"to,omitempty"`    // куда переносим трек для "move"
+	Video *Video    `json:"video,omitempty"` // трек для "play_next"
+
+	User int    `json:"user,omitempty"` // кому меняем роль для "role"
+	Role string `json:"role,omitempty"` // новая роль для "role": dj или listener
+
+	Version uint64 `json:"version,omitempty"` // ожидаемая версия state, 0 — без проверки
 }
 
 type ErrorResponse struct {
+	Code    string `json:"code"` // машиночитаемый код: room_not_found, version_conflict, ...
 	Message string `json:"message"`
 }
 
 type Room struct {
-	ID          uuid.UUID `json:"id"`
-	Queue       []*Video  `json:"queue"`
-	Current     *Video    `json:"current"`
-	Playing     bool      `json:"playing"`
-	Subscribers int       `json:"subscribers"`
+	ID          uuid.UUID     `json:"id"`
+	Queue       []*QueueEntry `json:"queue"`
+	Current     *QueueEntry   `json:"current"`
+	Playing     bool          `json:"playing"`
+	Private     bool          `json:"private"`
+	Protected   bool          `json:"protected"` // вход по паролю
+	Repeat      string        `json:"repeat"`
+	Version     uint64        `json:"version"`
+	Subscribers int           `json:"subscribers"`
+	Settings    RoomSettings  `json:"settings"`
+	CreatedAt   time.Time     `json:"created_at"`
 }
 
 type State struct {
-	ID        uuid.UUID `json:"id"`
-	Current   *Video    `json:"current"` // что играет (может быть nil)
-	Queue     []*Video  `json:"queue"`   // копия очереди
-	Playing   bool      `json:"playing"`
-	Position  float64   `json:"position"`   // на какой секунде сейчас должен быть трек
-	UpdatedAt time.Time `json:"updated_at"` // когда этот state посчитали
+	ID        uuid.UUID     `json:"id"`
+	Current   *QueueEntry   `json:"current"` // что играет (может быть nil)
+	Queue     []*QueueEntry `json:"queue"`   // копия очереди
+	Playing   bool          `json:"playing"`
+	Repeat    string        `json:"repeat"`     // режим повтора: off, one, all
+	Version   uint64        `json:"version"`    // растет на каждое изменение комнаты, кроме входа и выхода участников
+	Position  float64       `json:"position"`   // на какой секунде сейчас должен быть трек
+	UpdatedAt time.Time     `json:"updated_at"` // когда этот state посчитали
+
+	Votes       int `json:"votes"`        // сколько голосов за пропуск текущего трека
+	VotesNeeded int `json:"votes_needed"` // сколько голосов нужно для пропуска
+
+	Members []Member `json:"members"` // кто подключен и с какой ролью
+
+	Settings  RoomSettings `json:"settings"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// RoomQuery — фильтры и страница каталога комнат
+type RoomQuery struct {
+	Cursor     string // откуда продолжать, из NextCursor прошлой страницы
+	Limit      int    // размер страницы
+	Sort       string // SortCreated или SortListeners
+	Visibility string // VisibilityPublic, VisibilityPrivate или пусто — все
+	Name       string // подстрока имени без учета регистра
+	Playing    *bool  // nil — неважно
+}
+
+// RoomSummary — комната в каталоге, без очереди
+type RoomSummary struct {
+	ID          uuid.UUID   `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Private     bool        `json:"private"`
+	Protected   bool        `json:"protected"`
+	Subscribers int         `json:"subscribers"`
+	Playing     bool        `json:"playing"`
+	Current     *QueueEntry `json:"current"`
+	Position    float64     `json:"position"`
+	QueueLength int         `json:"queue_length"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+type RoomPage struct {
+	Rooms      []*RoomSummary `json:"rooms"`
+	NextCursor string         `json:"next_cursor,omitempty"` // пусто — страниц больше нет
 }
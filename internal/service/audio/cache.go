@@ -0,0 +1,142 @@
+package audio
+
+import (
+	"container/list"
+	"mrs/internal/dto"
+	"sync"
+	"time"
+)
+
+// cache — LRU с временем жизни записей: при переполнении вытесняется давно не читанная запись,
+// протухшая запись удаляется при чтении
+type cache[V any] struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List // спереди — недавно использованные
+	items map[string]*list.Element
+
+	hits      uint64
+	misses    uint64
+	coalesced uint64
+}
+
+type cacheItem[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+// newCache — size <= 0 или ttl <= 0 выключают кеш: get всегда промах, set ничего не делает
+func newCache[V any](size int, ttl time.Duration) *cache[V] {
+	return &cache[V]{size: size, ttl: ttl, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *cache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		item := el.Value.(*cacheItem[V])
+		if time.Now().Before(item.expiresAt) {
+			c.order.MoveToFront(el)
+			c.hits++
+			return item.value, true
+		}
+
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+
+	c.misses++
+
+	var zero V
+	return zero, false
+}
+
+func (c *cache[V]) set(key string, value V) {
+	if c.size <= 0 || c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+
+	if el, ok := c.items[key]; ok {
+		item := el.Value.(*cacheItem[V])
+		item.value = value
+		item.expiresAt = expiresAt
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&cacheItem[V]{key: key, value: value, expiresAt: expiresAt})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheItem[V]).key)
+	}
+}
+
+// coalesce отмечает запрос, который дождался чужого похода в YouTube вместо своего
+func (c *cache[V]) coalesce() {
+	c.mu.Lock()
+	c.coalesced++
+	c.mu.Unlock()
+}
+
+func (c *cache[V]) stats() dto.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return dto.CacheStats{
+		Size:      c.order.Len(),
+		Capacity:  c.size,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Coalesced: c.coalesced,
+	}
+}
+
+// flight — один поход в YouTube, результат которого ждут все одинаковые запросы
+type flight[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// flightGroup склеивает одновременные одинаковые запросы в один
+type flightGroup[V any] struct {
+	mu      sync.Mutex
+	flights map[string]*flight[V]
+}
+
+// do выполняет fn один раз на ключ, пока он в работе; shared — результат чужого вызова
+func (g *flightGroup[V]) do(key string, fn func() (V, error)) (value V, shared bool, err error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight[V])
+	}
+
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		<-f.done
+		return f.value, true, f.err
+	}
+
+	f := &flight[V]{done: make(chan struct{})}
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	f.value, f.err = fn()
+
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+	close(f.done)
+
+	return f.value, false, f.err
+}
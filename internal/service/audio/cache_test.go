@@ -0,0 +1,252 @@
+package audio
+
+import (
+	"errors"
+	"mrs/internal/dto"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheLRU(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		ops  []string // "set:k" или "get:k"
+		want map[string]bool
+	}{
+		{
+			name: "oldest evicted",
+			size: 2,
+			ops:  []string{"set:a", "set:b", "set:c"},
+			want: map[string]bool{"a": false, "b": true, "c": true},
+		},
+		{
+			name: "read keeps entry",
+			size: 2,
+			ops:  []string{"set:a", "set:b", "get:a", "set:c"},
+			want: map[string]bool{"a": true, "b": false, "c": true},
+		},
+		{
+			name: "overwrite keeps entry",
+			size: 2,
+			ops:  []string{"set:a", "set:b", "set:a", "set:c"},
+			want: map[string]bool{"a": true, "b": false, "c": true},
+		},
+		{
+			name: "zero size disables cache",
+			size: 0,
+			ops:  []string{"set:a"},
+			want: map[string]bool{"a": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCache[string](tt.size, time.Minute)
+
+			for _, op := range tt.ops {
+				key := op[4:]
+				switch op[:3] {
+				case "set":
+					c.set(key, "v"+key)
+				case "get":
+					c.get(key)
+				}
+			}
+
+			for key, want := range tt.want {
+				value, ok := c.get(key)
+				if ok != want {
+					t.Errorf("get(%q) found = %v, want %v", key, ok, want)
+				}
+				if ok && value != "v"+key {
+					t.Errorf("get(%q) = %q, want %q", key, value, "v"+key)
+				}
+			}
+
+			if size := c.stats().Size; size > max(tt.size, 0) {
+				t.Errorf("size = %d, capacity %d", size, tt.size)
+			}
+		})
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c := newCache[string](10, 50*time.Millisecond)
+
+	c.set("a", "1")
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("fresh entry is missing")
+	}
+
+	time.Sleep(80 * time.Millisecond)
+
+	if _, ok := c.get("a"); ok {
+		t.Fatal("expired entry is returned")
+	}
+
+	// протухшая запись удалена, а не только спрятана
+	if size := c.stats().Size; size != 0 {
+		t.Errorf("size after expiry = %d, want 0", size)
+	}
+
+	// перезапись продлевает жизнь записи
+	c.set("b", "1")
+	time.Sleep(30 * time.Millisecond)
+	c.set("b", "2")
+	time.Sleep(30 * time.Millisecond)
+
+	if value, ok := c.get("b"); !ok || value != "2" {
+		t.Errorf("get(b) = %q, %v, want 2, true", value, ok)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	c := newCache[string](2, time.Minute)
+
+	c.set("a", "1")
+	c.get("a")
+	c.get("a")
+	c.get("b")
+	c.coalesce()
+
+	want := dto.CacheStats{Size: 1, Capacity: 2, Hits: 2, Misses: 1, Coalesced: 1}
+	if got := c.stats(); got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestFlightGroupCoalesces(t *testing.T) {
+	const waiters = 10
+
+	var g flightGroup[string]
+	var calls atomic.Int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]string, waiters)
+	shared := make([]bool, waiters)
+
+	// первый вызов держит ключ, пока его не отпустят
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], shared[0], _ = g.do("q", func() (string, error) {
+			calls.Add(1)
+			close(started)
+			<-release
+			return "page", nil
+		})
+	}()
+	<-started
+
+	for i := 1; i < waiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], shared[i], _ = g.do("q", func() (string, error) {
+				calls.Add(1)
+				return "other", nil
+			})
+		}()
+	}
+
+	// даем остальным встать в ожидание первого вызова
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("fn called %d times, want 1", n)
+	}
+
+	for i := range waiters {
+		if results[i] != "page" {
+			t.Errorf("result %d = %q, want page", i, results[i])
+		}
+		if shared[i] != (i != 0) {
+			t.Errorf("shared %d = %v, want %v", i, shared[i], i != 0)
+		}
+	}
+}
+
+func TestFlightGroup(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name string
+		keys []string
+		err  error
+		want int32 // сколько раз выполнится fn
+	}{
+		{name: "different keys run separately", keys: []string{"a", "b", "c"}, want: 3},
+		{name: "same key after finish runs again", keys: []string{"a", "a"}, want: 2},
+		{name: "error is returned", keys: []string{"a"}, err: errFailed, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var g flightGroup[int]
+			var calls atomic.Int32
+
+			for _, key := range tt.keys {
+				_, shared, err := g.do(key, func() (int, error) {
+					calls.Add(1)
+					return 1, tt.err
+				})
+				if shared {
+					t.Errorf("do(%q) shared a finished call", key)
+				}
+				if !errors.Is(err, tt.err) {
+					t.Errorf("do(%q) err = %v, want %v", key, err, tt.err)
+				}
+			}
+
+			if n := calls.Load(); n != tt.want {
+				t.Errorf("fn called %d times, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlightGroupSharesError(t *testing.T) {
+	var g flightGroup[int]
+	errFailed := errors.New("failed")
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _, errs[0] = g.do("q", func() (int, error) {
+			close(started)
+			<-release
+			return 0, errFailed
+		})
+	}()
+	<-started
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _, errs[1] = g.do("q", func() (int, error) {
+			return 1, nil
+		})
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if !errors.Is(err, errFailed) {
+			t.Errorf("err %d = %v, want %v", i, err, errFailed)
+		}
+	}
+}
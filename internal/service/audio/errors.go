@@ -0,0 +1,20 @@
+package audio
+
+import "errors"
+
+var (
+	ErrUnknownProvider = errors.New("music provider is not enabled")
+
+	ErrEmptyQuery    = errors.New("search query is empty")
+	ErrInvalidPage   = errors.New("invalid page")
+	ErrInvalidVideo  = errors.New("invalid video")
+	ErrVideoNotFound = errors.New("video does not exist or is not available")
+
+	ErrInvalidPlaylist  = errors.New("invalid playlist")
+	ErrPlaylistNotFound = errors.New("playlist does not exist or is not available")
+
+	// YouTube не ответил или ответил чем-то, что не удалось разобрать
+	ErrUpstream = errors.New("youtube request failed")
+	// дневная квота YouTube кончилась, конкретная ошибка — *QuotaError с временем сброса
+	ErrQuotaExhausted = errors.New("youtube quota exhausted")
+)
@@ -0,0 +1,156 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+	"mrs/internal/dto"
+	"net/http"
+	"strings"
+)
+
+// причины, по которым ключ выключен до сброса квоты
+const (
+	blockedQuota   = "quota_exceeded"
+	blockedInvalid = "key_invalid"
+)
+
+// apiKey — ключ YouTube Data API со своим клиентом и своей квотой
+type apiKey struct {
+	name    string // ключ без секрета, для отчета
+	youtube *youtube.Service
+	quota   *quota
+}
+
+func newAPIKeys(ctx context.Context, tokens []string, budget int) ([]*apiKey, error) {
+	keys := make([]*apiKey, 0, len(tokens))
+	for _, token := range tokens {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+
+		service, err := youtube.NewService(ctx, option.WithAPIKey(token))
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, &apiKey{name: maskKey(token), youtube: service, quota: newQuota(budget)})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("at least one youtube api key (TOKEN) is required")
+	}
+
+	return keys, nil
+}
+
+// maskKey оставляет от ключа последние четыре символа
+func maskKey(token string) string {
+	if len(token) <= 4 {
+		return "…"
+	}
+
+	return "…" + token[len(token)-4:]
+}
+
+// blockReason — из-за чего YouTube отказал ключу: кончилась квота или ключ не принят
+func blockReason(err error) string {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+
+	for _, item := range apiErr.Errors {
+		switch {
+		case apiErr.Code == http.StatusForbidden && (item.Reason == "quotaExceeded" || item.Reason == "dailyLimitExceeded"):
+			return blockedQuota
+		case item.Reason == "keyInvalid" || item.Reason == "keyExpired":
+			return blockedInvalid
+		}
+	}
+
+	return ""
+}
+
+// call выполняет запрос к YouTube первым ключом, у которого хватает квоты.
+// Если YouTube отказал ключу из-за квоты или ключ не принят, ключ выключается до сброса квоты
+// и запрос повторяется следующим. Когда ключи кончились — *QuotaError с ближайшим сбросом
+func call[T any](s *YouTube, op string, do func(yt *youtube.Service) (T, error)) (T, error) {
+	var zero T
+	var quotaErr *QuotaError
+
+	for _, key := range s.keys {
+		if err := key.quota.spend(op); err != nil {
+			quotaErr = earlier(quotaErr, err)
+			continue
+		}
+
+		res, err := do(key.youtube)
+		if err == nil {
+			return res, nil
+		}
+
+		reason := blockReason(err)
+		if reason == "" {
+			return zero, err
+		}
+
+		quotaErr = earlier(quotaErr, key.quota.block(reason))
+	}
+
+	if quotaErr == nil {
+		return zero, ErrQuotaExhausted
+	}
+
+	return zero, quotaErr
+}
+
+// earlier выбирает из двух ошибок квоты ту, что сбросится раньше
+func earlier(cur *QuotaError, err error) *QuotaError {
+	var next *QuotaError
+	if !errors.As(err, &next) {
+		return cur
+	}
+
+	if cur == nil || next.ResetAt.Before(cur.ResetAt) {
+		return next
+	}
+
+	return cur
+}
+
+// QuotaReport отдает расход квоты за текущие сутки: по каждому ключу и в сумме
+func (s *YouTube) QuotaReport() dto.QuotaReport {
+	report := dto.QuotaReport{
+		Exhausted:  true,
+		Operations: make(map[string]dto.QuotaUsage),
+		Keys:       make([]dto.KeyQuota, 0, len(s.keys)),
+	}
+
+	for _, key := range s.keys {
+		kq := key.quota.report()
+		kq.Key = key.name
+
+		report.Budget += kq.Budget
+		report.Used += kq.Used
+		report.Remaining += kq.Remaining
+		report.Exhausted = report.Exhausted && kq.Exhausted
+		if report.ResetAt.IsZero() || kq.ResetAt.Before(report.ResetAt) {
+			report.ResetAt = kq.ResetAt
+		}
+
+		for op, usage := range kq.Operations {
+			total := report.Operations[op]
+			total.Calls += usage.Calls
+			total.Units += usage.Units
+			report.Operations[op] = total
+		}
+
+		report.Keys = append(report.Keys, kq)
+	}
+
+	return report
+}
@@ -0,0 +1,294 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// ответы YouTube, которые отдает поддельный ключ
+const (
+	respOK      = "ok"
+	respQuota   = "quotaExceeded"
+	respDaily   = "dailyLimitExceeded"
+	respInvalid = "keyInvalid"
+	respExpired = "keyExpired"
+	respFail    = "backendError"
+)
+
+func apiError(reason string) error {
+	switch reason {
+	case respOK:
+		return nil
+	case respQuota, respDaily:
+		return &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: reason}}}
+	case respInvalid, respExpired:
+		return &googleapi.Error{Code: http.StatusBadRequest, Errors: []googleapi.ErrorItem{{Reason: reason}}}
+	default:
+		return &googleapi.Error{Code: http.StatusInternalServerError, Errors: []googleapi.ErrorItem{{Reason: reason}}}
+	}
+}
+
+// newTestYouTube — провайдер с поддельными ключами, у каждого свой бюджет
+func newTestYouTube(budgets ...int) *YouTube {
+	s := &YouTube{}
+	for i, budget := range budgets {
+		s.keys = append(s.keys, &apiKey{name: maskKey(fmt.Sprintf("test-key-%d", i)), youtube: &youtube.Service{}, quota: newQuota(budget)})
+	}
+
+	return s
+}
+
+// keyIndex — какому ключу принадлежит клиент
+func keyIndex(s *YouTube, yt *youtube.Service) int {
+	for i, key := range s.keys {
+		if key.youtube == yt {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func TestCallRotation(t *testing.T) {
+	tests := []struct {
+		name      string
+		budgets   []int
+		responses []string // ответ YouTube каждому ключу
+		wantCalls []int    // каким ключам ушел запрос, по порядку
+		wantUsed  int      // ключ, чей ответ вернулся; -1 — ошибка
+		wantErr   error
+		blocked   []string // причина блокировки каждого ключа после вызова
+	}{
+		{
+			name:      "first key answers",
+			budgets:   []int{1000, 1000},
+			responses: []string{respOK, respOK},
+			wantCalls: []int{0},
+			wantUsed:  0,
+			blocked:   []string{"", ""},
+		},
+		{
+			name:      "quota exceeded moves to next key",
+			budgets:   []int{1000, 1000},
+			responses: []string{respQuota, respOK},
+			wantCalls: []int{0, 1},
+			wantUsed:  1,
+			blocked:   []string{blockedQuota, ""},
+		},
+		{
+			name:      "daily limit moves to next key",
+			budgets:   []int{1000, 1000},
+			responses: []string{respDaily, respOK},
+			wantCalls: []int{0, 1},
+			wantUsed:  1,
+			blocked:   []string{blockedQuota, ""},
+		},
+		{
+			name:      "invalid and expired keys are skipped",
+			budgets:   []int{1000, 1000, 1000},
+			responses: []string{respInvalid, respExpired, respOK},
+			wantCalls: []int{0, 1, 2},
+			wantUsed:  2,
+			blocked:   []string{blockedInvalid, blockedInvalid, ""},
+		},
+		{
+			name:      "key without budget is not called",
+			budgets:   []int{50, 1000},
+			responses: []string{respOK, respOK},
+			wantCalls: []int{1},
+			wantUsed:  1,
+			blocked:   []string{"", ""},
+		},
+		{
+			name:      "other errors are not retried",
+			budgets:   []int{1000, 1000},
+			responses: []string{respFail, respOK},
+			wantCalls: []int{0},
+			wantUsed:  -1,
+			wantErr:   &googleapi.Error{},
+			blocked:   []string{"", ""},
+		},
+		{
+			name:      "all keys refused",
+			budgets:   []int{1000, 1000},
+			responses: []string{respQuota, respInvalid},
+			wantCalls: []int{0, 1},
+			wantUsed:  -1,
+			wantErr:   ErrQuotaExhausted,
+			blocked:   []string{blockedQuota, blockedInvalid},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestYouTube(tt.budgets...)
+
+			var calls []int
+			used, err := call(s, opSearch, func(yt *youtube.Service) (int, error) {
+				i := keyIndex(s, yt)
+				calls = append(calls, i)
+				if err := apiError(tt.responses[i]); err != nil {
+					return -1, err
+				}
+				return i, nil
+			})
+
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+			case *googleapi.Error:
+				if !errors.As(err, &want) {
+					t.Fatalf("err = %v, want *googleapi.Error", err)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Fatalf("err = %v, want %v", err, want)
+				}
+			}
+
+			if err == nil && used != tt.wantUsed {
+				t.Errorf("used key %d, want %d", used, tt.wantUsed)
+			}
+
+			if len(calls) != len(tt.wantCalls) {
+				t.Fatalf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			for i := range calls {
+				if calls[i] != tt.wantCalls[i] {
+					t.Fatalf("calls = %v, want %v", calls, tt.wantCalls)
+				}
+			}
+
+			for i, key := range s.keys {
+				if got := key.quota.report().Blocked; got != tt.blocked[i] {
+					t.Errorf("key %d blocked = %q, want %q", i, got, tt.blocked[i])
+				}
+			}
+		})
+	}
+}
+
+// выключенный ключ не получает запросов до сброса квоты
+func TestCallSkipsBlockedKey(t *testing.T) {
+	s := newTestYouTube(1000, 1000)
+
+	responses := []string{respQuota, respOK}
+	calls := make([]int, len(s.keys))
+	do := func(yt *youtube.Service) (int, error) {
+		i := keyIndex(s, yt)
+		calls[i]++
+		return i, apiError(responses[i])
+	}
+
+	for range 3 {
+		if _, err := call(s, opVideos, do); err != nil {
+			t.Fatalf("call: %v", err)
+		}
+	}
+
+	if calls[0] != 1 || calls[1] != 3 {
+		t.Errorf("calls per key = %v, want [1 3]", calls)
+	}
+}
+
+func TestCallEarliestReset(t *testing.T) {
+	s := newTestYouTube(1, 1, 1)
+
+	now := time.Now()
+	resets := []time.Duration{3 * time.Hour, time.Hour, 2 * time.Hour}
+	for i, key := range s.keys {
+		key.quota.resetAt = now.Add(resets[i])
+	}
+
+	// бюджета в 1 единицу на поиск не хватает ни одному ключу
+	_, err := call(s, opSearch, func(yt *youtube.Service) (int, error) {
+		t.Fatal("key without budget was called")
+		return 0, nil
+	})
+
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("err = %v, want *QuotaError", err)
+	}
+	if want := now.Add(time.Hour); !quotaErr.ResetAt.Equal(want) {
+		t.Errorf("ResetAt = %v, want %v", quotaErr.ResetAt, want)
+	}
+	if !errors.Is(err, ErrQuotaExhausted) {
+		t.Errorf("err = %v, want it to wrap %v", err, ErrQuotaExhausted)
+	}
+}
+
+func TestBlockReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"quota exceeded", apiError(respQuota), blockedQuota},
+		{"daily limit", apiError(respDaily), blockedQuota},
+		{"key invalid", apiError(respInvalid), blockedInvalid},
+		{"key expired", apiError(respExpired), blockedInvalid},
+		{"backend error", apiError(respFail), ""},
+		{"quota reason with other status", &googleapi.Error{Code: http.StatusTooManyRequests, Errors: []googleapi.ErrorItem{{Reason: respQuota}}}, ""},
+		{"not a google error", errors.New("dial tcp: timeout"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blockReason(tt.err); got != tt.want {
+				t.Errorf("blockReason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEarlier(t *testing.T) {
+	now := time.Now()
+	soon := &QuotaError{ResetAt: now.Add(time.Hour)}
+	late := &QuotaError{ResetAt: now.Add(2 * time.Hour)}
+
+	tests := []struct {
+		name string
+		cur  *QuotaError
+		err  error
+		want *QuotaError
+	}{
+		{"first error", nil, late, late},
+		{"earlier replaces", late, soon, soon},
+		{"later is ignored", soon, late, soon},
+		{"not a quota error", soon, errors.New("other"), soon},
+		{"nothing yet", nil, errors.New("other"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := earlier(tt.cur, tt.err); got != tt.want {
+				t.Errorf("earlier = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskKey(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"AIzaSECRETKEY1234", "…1234"},
+		{"abcd", "…"},
+		{"", "…"},
+	}
+
+	for _, tt := range tests {
+		if got := maskKey(tt.token); got != tt.want {
+			t.Errorf("maskKey(%q) = %q, want %q", tt.token, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+	"mrs/internal/dto"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	status = "status"
+
+	playlistIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{2,64}$`)
+)
+
+// ParsePlaylistID достает айди плейлиста из ссылки YouTube (параметр list) или принимает сам айди
+func ParsePlaylistID(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("%w: url is required", ErrInvalidPlaylist)
+	}
+
+	if playlistIDPattern.MatchString(raw) {
+		return raw, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("%w: url must be an http(s) youtube link", ErrInvalidPlaylist)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if !watchHosts[host] && host != "youtu.be" {
+		return "", fmt.Errorf("%w: %s is not a youtube host", ErrInvalidPlaylist, host)
+	}
+
+	playlistID := u.Query().Get("list")
+	if !playlistIDPattern.MatchString(playlistID) {
+		return "", fmt.Errorf("%w: no playlist id in url", ErrInvalidPlaylist)
+	}
+
+	return playlistID, nil
+}
+
+// Playlist разворачивает плейлист в список видео с названиями и длительностями.
+// Закрытые и удаленные видео пропускаются, больше playlistLimit видео не берется (0 — без ограничения)
+func (s *YouTube) Playlist(ctx context.Context, raw string) (*dto.Playlist, error) {
+	playlistID, err := ParsePlaylistID(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	playlist := &dto.Playlist{}
+	var ids []string
+
+	// листаем плейлист, пока не кончится или не наберем лимит
+	pageToken := ""
+	for {
+		response, err := call(s, opPlaylistItems, func(yt *youtube.Service) (*youtube.PlaylistItemListResponse, error) {
+			return yt.PlaylistItems.List([]string{contentDetails, status}).
+				PlaylistId(playlistID).MaxResults(maxPageSize).PageToken(pageToken).Context(ctx).Do()
+		})
+		if err != nil {
+			var apiErr *googleapi.Error
+			if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+				return nil, ErrPlaylistNotFound
+			}
+			return nil, upstreamError(err)
+		}
+
+		for _, item := range response.Items {
+			if item.Status != nil && item.Status.PrivacyStatus == "private" {
+				playlist.Skipped++
+				continue
+			}
+
+			if s.playlistLimit > 0 && len(ids) == s.playlistLimit {
+				playlist.Truncated = true
+				break
+			}
+
+			ids = append(ids, item.ContentDetails.VideoId)
+		}
+
+		pageToken = response.NextPageToken
+		if pageToken == "" || playlist.Truncated {
+			break
+		}
+	}
+
+	// длительности добираем пачками, удаленных видео в ответе Videos.List нет
+	for start := 0; start < len(ids); start += maxPageSize {
+		batch := ids[start:min(start+maxPageSize, len(ids))]
+
+		videos, err := s.lookupVideos(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, videoID := range batch {
+			video, ok := videos[videoID]
+			if !ok {
+				playlist.Skipped++
+				continue
+			}
+			playlist.Videos = append(playlist.Videos, video)
+		}
+	}
+
+	if len(playlist.Videos) == 0 {
+		return nil, fmt.Errorf("%w: no available videos", ErrPlaylistNotFound)
+	}
+
+	return playlist, nil
+}
@@ -0,0 +1,139 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"mrs/internal/dto"
+)
+
+const ProviderYouTube = "youtube"
+
+// Provider — источник музыки: поиск, трек по ссылке или айди, разворачивание плейлиста.
+// Треки провайдера помечаются его именем в dto.Video.Provider
+type Provider interface {
+	Name() string
+	// Match — ссылка или айди относятся к этому провайдеру
+	Match(raw string) bool
+	Search(ctx context.Context, query dto.SearchQuery) (*dto.SearchPage, error)
+	Lookup(ctx context.Context, ref string) (*dto.Video, error)
+	Playlist(ctx context.Context, ref string) (*dto.Playlist, error)
+}
+
+// провайдеры, которые ведут учет кеша и квоты, отдают его в админские эндпоинты
+type cacheReporter interface {
+	CacheStats() dto.CacheReport
+}
+
+type quotaReporter interface {
+	QuotaReport() dto.QuotaReport
+}
+
+// ServiceAudio выбирает провайдера под запрос. Первый провайдер — провайдер по умолчанию
+type ServiceAudio struct {
+	providers []Provider
+	byName    map[string]Provider
+}
+
+func NewServiceAudio(providers ...Provider) (*ServiceAudio, error) {
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("at least one music provider is required")
+	}
+
+	byName := make(map[string]Provider, len(providers))
+	for _, p := range providers {
+		if _, ok := byName[p.Name()]; ok {
+			return nil, fmt.Errorf("provider %s is enabled twice", p.Name())
+		}
+		byName[p.Name()] = p
+	}
+
+	return &ServiceAudio{providers: providers, byName: byName}, nil
+}
+
+// provider отдает провайдера по имени, пустое имя — провайдер по умолчанию
+func (s *ServiceAudio) provider(name string) (Provider, error) {
+	if name == "" {
+		return s.providers[0], nil
+	}
+
+	p, ok := s.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+
+	return p, nil
+}
+
+// providerFor — провайдер по имени, а без имени тот, кто узнает ссылку, иначе провайдер по умолчанию
+func (s *ServiceAudio) providerFor(name, ref string) (Provider, error) {
+	if name != "" {
+		return s.provider(name)
+	}
+
+	for _, p := range s.providers {
+		if p.Match(ref) {
+			return p, nil
+		}
+	}
+
+	return s.providers[0], nil
+}
+
+// GetListVideo ищет у провайдера из запроса
+func (s *ServiceAudio) GetListVideo(ctx context.Context, query dto.SearchQuery) (*dto.SearchPage, error) {
+	p, err := s.provider(query.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Search(ctx, query)
+}
+
+// ResolveVideo находит трек по ссылке или айди и отдает его с названием и длительностью от провайдера.
+// Что прислал клиент кроме провайдера и ссылки, не используется
+func (s *ServiceAudio) ResolveVideo(ctx context.Context, video *dto.Video) (*dto.Video, error) {
+	if video == nil {
+		return nil, fmt.Errorf("%w: video is required", ErrInvalidVideo)
+	}
+
+	p, err := s.providerFor(video.Provider, video.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Lookup(ctx, video.URL)
+}
+
+// GetPlaylist разворачивает плейлист у провайдера из запроса или того, кто узнает ссылку
+func (s *ServiceAudio) GetPlaylist(ctx context.Context, req dto.PlaylistRequest) (*dto.Playlist, error) {
+	p, err := s.providerFor(req.Provider, req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Playlist(ctx, req.URL)
+}
+
+// CacheStats отдает счетчики кешей по провайдерам
+func (s *ServiceAudio) CacheStats() map[string]dto.CacheReport {
+	reports := make(map[string]dto.CacheReport)
+	for _, p := range s.providers {
+		if r, ok := p.(cacheReporter); ok {
+			reports[p.Name()] = r.CacheStats()
+		}
+	}
+
+	return reports
+}
+
+// QuotaReport отдает расход квоты по провайдерам, у которых она есть
+func (s *ServiceAudio) QuotaReport() map[string]dto.QuotaReport {
+	reports := make(map[string]dto.QuotaReport)
+	for _, p := range s.providers {
+		if r, ok := p.(quotaReporter); ok {
+			reports[p.Name()] = r.QuotaReport()
+		}
+	}
+
+	return reports
+}
@@ -0,0 +1,179 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mrs/internal/dto"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// операции YouTube Data API и сколько единиц квоты они стоят
+const (
+	opSearch        = "search.list"
+	opVideos        = "videos.list"
+	opPlaylistItems = "playlistItems.list"
+)
+
+var operationCost = map[string]int{
+	opSearch:        100,
+	opVideos:        1,
+	opPlaylistItems: 1,
+}
+
+// квота YouTube обнуляется в полночь по тихоокеанскому времени
+var quotaLocation = pacificTime()
+
+func pacificTime() *time.Location {
+	if loc, err := time.LoadLocation("America/Los_Angeles"); err == nil {
+		return loc
+	}
+
+	// в образе без tzdata считаем без перехода на летнее время
+	return time.FixedZone("PST", -8*60*60)
+}
+
+// QuotaError — дневная квота YouTube израсходована до ResetAt
+type QuotaError struct {
+	ResetAt time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s until %s", ErrQuotaExhausted, e.ResetAt.UTC().Format(time.RFC3339))
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExhausted
+}
+
+// RetryAfter — через сколько квота снова появится, транспорт отдает это в Retry-After
+func (e *QuotaError) RetryAfter() time.Duration {
+	return time.Until(e.ResetAt)
+}
+
+// quota считает примерный расход дневной квоты одного ключа по операциям.
+// Единицы списываются до запроса: YouTube берет их и за запросы, которые закончились ошибкой
+type quota struct {
+	mu      sync.Mutex
+	budget  int // 0 — без ограничения, только учет
+	used    int
+	blocked string // blockedQuota или blockedInvalid — YouTube отказал ключу, до сброса не ходим
+	resetAt time.Time
+	calls   map[string]int
+	units   map[string]int
+}
+
+func newQuota(budget int) *quota {
+	q := &quota{budget: budget}
+	q.resetLocked(time.Now())
+	return q
+}
+
+func nextReset(now time.Time) time.Time {
+	local := now.In(quotaLocation)
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, quotaLocation)
+}
+
+func (q *quota) resetLocked(now time.Time) {
+	q.used = 0
+	q.blocked = ""
+	q.resetAt = nextReset(now)
+	q.calls = make(map[string]int)
+	q.units = make(map[string]int)
+}
+
+func (q *quota) rollLocked(now time.Time) {
+	if !now.Before(q.resetAt) {
+		q.resetLocked(now)
+	}
+}
+
+// spend списывает стоимость операции или отказывает, если бюджета на нее не хватит
+func (q *quota) spend(op string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollLocked(time.Now())
+
+	cost := operationCost[op]
+	if q.blocked != "" || (q.budget > 0 && q.used+cost > q.budget) {
+		return &QuotaError{ResetAt: q.resetAt}
+	}
+
+	q.used += cost
+	q.calls[op]++
+	q.units[op] += cost
+
+	return nil
+}
+
+// block — YouTube отказал ключу (наш подсчет квоты разошелся с его или ключ не принят): до сброса не ходим
+func (q *quota) block(reason string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollLocked(time.Now())
+	q.blocked = reason
+
+	return &QuotaError{ResetAt: q.resetAt}
+}
+
+func (q *quota) report() dto.KeyQuota {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollLocked(time.Now())
+
+	report := dto.KeyQuota{
+		Budget:     q.budget,
+		Used:       q.used,
+		Exhausted:  q.blocked != "" || (q.budget > 0 && q.used >= q.budget),
+		Blocked:    q.blocked,
+		ResetAt:    q.resetAt,
+		Operations: make(map[string]dto.QuotaUsage, len(q.calls)),
+	}
+
+	if q.budget > 0 && q.blocked == "" {
+		report.Remaining = max(q.budget-q.used, 0)
+	}
+
+	for op, calls := range q.calls {
+		report.Operations[op] = dto.QuotaUsage{Calls: calls, Units: q.units[op]}
+	}
+
+	return report
+}
+
+// upstreamError пишет ошибку YouTube в лог и отдает вместо нее голый ErrUpstream, ошибку квоты отдает как есть.
+// Текст ошибки клиента Google содержит адрес запроса вместе с ключом, наружу его не отдаем
+func upstreamError(err error) error {
+	if errors.Is(err, ErrQuotaExhausted) {
+		return err
+	}
+
+	log.Printf("youtube request failed: %v", redactKey(err))
+	return ErrUpstream
+}
+
+// redactKey прячет ключ в адресе запроса, чтобы он не попал и в лог
+func redactKey(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+
+	u, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		return urlErr.Err
+	}
+
+	q := u.Query()
+	if key := q.Get("key"); key != "" {
+		q.Set("key", maskKey(key))
+		u.RawQuery = q.Encode()
+	}
+
+	return &url.Error{Op: urlErr.Op, URL: u.String(), Err: urlErr.Err}
+}
@@ -0,0 +1,66 @@
+package audio
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// айди видео на YouTube — 11 символов base64url
+var videoIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// хосты, на которых открывается видео по ?v=
+var watchHosts = map[string]bool{
+	"youtube.com":       true,
+	"www.youtube.com":   true,
+	"m.youtube.com":     true,
+	"music.youtube.com": true,
+}
+
+// CanonicalURL — ссылка, под которой видео хранится в очереди и истории
+func CanonicalURL(videoID string) string {
+	return fmt.Sprintf("https://www.youtube.com/watch?v=%s", videoID)
+}
+
+// ParseVideoID достает айди видео из ссылки YouTube (watch?v=, youtu.be/, shorts/, embed/, live/)
+// или принимает сам айди
+func ParseVideoID(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("%w: url is required", ErrInvalidVideo)
+	}
+
+	if videoIDPattern.MatchString(raw) {
+		return raw, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("%w: url must be an http(s) youtube link", ErrInvalidVideo)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	path := strings.Trim(u.Path, "/")
+
+	var videoID string
+	switch {
+	case host == "youtu.be":
+		videoID = path
+	case watchHosts[host] && path == "watch":
+		videoID = u.Query().Get("v")
+	case watchHosts[host] || host == "www.youtube-nocookie.com":
+		prefix, rest, ok := strings.Cut(path, "/")
+		if ok && (prefix == "shorts" || prefix == "embed" || prefix == "live") {
+			videoID = rest
+		}
+	default:
+		return "", fmt.Errorf("%w: %s is not a youtube host", ErrInvalidVideo, host)
+	}
+
+	if !videoIDPattern.MatchString(videoID) {
+		return "", fmt.Errorf("%w: no video id in url", ErrInvalidVideo)
+	}
+
+	return videoID, nil
+}
@@ -0,0 +1,122 @@
+package audio
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseVideoID(t *testing.T) {
+	const id = "dQw4w9WgXcQ"
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{name: "bare id", raw: id, want: id},
+		{name: "bare id with spaces", raw: "  " + id + "\n", want: id},
+		{name: "watch", raw: "https://www.youtube.com/watch?v=" + id, want: id},
+		{name: "watch with extra params", raw: "https://www.youtube.com/watch?list=PL123&v=" + id + "&t=42s", want: id},
+		{name: "watch without www", raw: "https://youtube.com/watch?v=" + id, want: id},
+		{name: "http", raw: "http://www.youtube.com/watch?v=" + id, want: id},
+		{name: "mobile", raw: "https://m.youtube.com/watch?v=" + id, want: id},
+		{name: "music", raw: "https://music.youtube.com/watch?v=" + id, want: id},
+		{name: "upper case host", raw: "https://WWW.YouTube.com/watch?v=" + id, want: id},
+		{name: "short link", raw: "https://youtu.be/" + id, want: id},
+		{name: "short link with time", raw: "https://youtu.be/" + id + "?t=10", want: id},
+		{name: "shorts", raw: "https://www.youtube.com/shorts/" + id, want: id},
+		{name: "embed", raw: "https://www.youtube.com/embed/" + id, want: id},
+		{name: "nocookie embed", raw: "https://www.youtube-nocookie.com/embed/" + id, want: id},
+		{name: "live", raw: "https://www.youtube.com/live/" + id, want: id},
+
+		{name: "empty", raw: "", wantErr: true},
+		{name: "too short id", raw: "dQw4w9WgXc", wantErr: true},
+		{name: "id with bad characters", raw: "dQw4w9WgX!Q", wantErr: true},
+		{name: "other host", raw: "https://vimeo.com/watch?v=" + id, wantErr: true},
+		{name: "lookalike host", raw: "https://youtube.com.evil.example/watch?v=" + id, wantErr: true},
+		{name: "no scheme", raw: "www.youtube.com/watch?v=" + id, wantErr: true},
+		{name: "other scheme", raw: "ftp://www.youtube.com/watch?v=" + id, wantErr: true},
+		{name: "watch without v", raw: "https://www.youtube.com/watch?list=PL123", wantErr: true},
+		{name: "channel page", raw: "https://www.youtube.com/@channel", wantErr: true},
+		{name: "unknown path", raw: "https://www.youtube.com/clip/" + id, wantErr: true},
+		{name: "short link with bad id", raw: "https://youtu.be/abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVideoID(tt.raw)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidVideo) {
+					t.Fatalf("ParseVideoID(%q) err = %v, want %v", tt.raw, err, ErrInvalidVideo)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseVideoID(%q): %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseVideoID(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePlaylistID(t *testing.T) {
+	const id = "PLrAXtmErZgOeiKm4sgNOknGvNjby9efdf"
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{name: "bare id", raw: id, want: id},
+		{name: "playlist page", raw: "https://www.youtube.com/playlist?list=" + id, want: id},
+		{name: "watch in playlist", raw: "https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=" + id + "&index=3", want: id},
+		{name: "music", raw: "https://music.youtube.com/playlist?list=" + id, want: id},
+		{name: "short link in playlist", raw: "https://youtu.be/dQw4w9WgXcQ?list=" + id, want: id},
+		{name: "mix", raw: "https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=RDdQw4w9WgXcQ", want: "RDdQw4w9WgXcQ"},
+
+		{name: "empty", raw: "", wantErr: true},
+		{name: "video without playlist", raw: "https://www.youtube.com/watch?v=dQw4w9WgXcQ", wantErr: true},
+		{name: "other host", raw: "https://example.com/playlist?list=" + id, wantErr: true},
+		{name: "bad characters", raw: "https://www.youtube.com/playlist?list=PL!bad", wantErr: true},
+		{name: "no scheme", raw: "youtube.com/playlist?list=" + id, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePlaylistID(tt.raw)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPlaylist) {
+					t.Fatalf("ParsePlaylistID(%q) err = %v, want %v", tt.raw, err, ErrInvalidPlaylist)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParsePlaylistID(%q): %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePlaylistID(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	const id = "dQw4w9WgXcQ"
+
+	// любая ссылка на видео сводится к одной и той же канонической
+	for _, raw := range []string{id, "https://youtu.be/" + id, "https://m.youtube.com/watch?v=" + id + "&t=1"} {
+		got, err := ParseVideoID(raw)
+		if err != nil {
+			t.Fatalf("ParseVideoID(%q): %v", raw, err)
+		}
+		if url := CanonicalURL(got); url != "https://www.youtube.com/watch?v="+id {
+			t.Errorf("CanonicalURL for %q = %q", raw, url)
+		}
+	}
+}
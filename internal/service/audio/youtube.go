@@ -0,0 +1,262 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sosodev/duration"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+	"mrs/internal/config"
+	"mrs/internal/dto"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// больше YouTube не отдает за страницу Search.List и PlaylistItems.List и не принимает айди в одном Videos.List
+	maxPageSize = 50
+	// размер страницы поиска, если LIMIT не задан
+	defaultPageSize = 5
+	// сколько ждем YouTube в общем для нескольких клиентов запросе
+	upstreamTimeout = 10 * time.Second
+)
+
+var (
+	typeQuery      = "video"
+	id             = "id"
+	snippet        = "snippet"
+	contentDetails = "contentDetails"
+)
+
+var _ Provider = (*YouTube)(nil)
+
+// YouTube — провайдер YouTube Data API: поиск, видео по ссылке или айди, плейлисты
+type YouTube struct {
+	keys          []*apiKey // в порядке из конфига, запрос уходит первому ключу с квотой
+	limit         int64
+	playlistLimit int // сколько видео максимум берем из одного плейлиста
+
+	searches *cache[*dto.SearchPage] // страницы поиска по запросу, токену и размеру страницы
+	videos   *cache[*dto.Video]      // названия и длительности по айди видео
+	inflight flightGroup[*dto.SearchPage]
+}
+
+func NewYouTube(cfg config.Youtube) (*YouTube, error) {
+	keys, err := newAPIKeys(context.Background(), cfg.Tokens, cfg.QuotaBudget)
+	if err != nil {
+		return nil, err
+	}
+
+	// LIMIT — размер страницы поиска по умолчанию, держим его в пределах, которые принимает YouTube
+	limit := cfg.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	limit = min(limit, maxPageSize)
+
+	return &YouTube{
+		keys:          keys,
+		limit:         limit,
+		playlistLimit: cfg.PlaylistLimit,
+		searches:      newCache[*dto.SearchPage](cfg.CacheSize, cfg.CacheTTL),
+		videos:        newCache[*dto.Video](cfg.CacheSize, cfg.CacheTTL),
+	}, nil
+}
+
+func (s *YouTube) Name() string {
+	return ProviderYouTube
+}
+
+// Match — ссылка или айди видео или плейлиста YouTube
+func (s *YouTube) Match(raw string) bool {
+	if _, err := ParseVideoID(raw); err == nil {
+		return true
+	}
+
+	_, err := ParsePlaylistID(raw)
+	return err == nil
+}
+
+// Search отдает страницу результатов поиска. Без размера страницы берется limit из конфига.
+// Страницы кешируются, одинаковые одновременные запросы идут в YouTube один раз
+func (s *YouTube) Search(ctx context.Context, query dto.SearchQuery) (*dto.SearchPage, error) {
+	query.Query = strings.TrimSpace(query.Query)
+	if query.Query == "" {
+		return nil, ErrEmptyQuery
+	}
+
+	if query.PageSize == 0 {
+		query.PageSize = s.limit
+	}
+
+	if query.PageSize < 0 || query.PageSize > maxPageSize {
+		return nil, fmt.Errorf("%w: page size must be in [1, %d]", ErrInvalidPage, maxPageSize)
+	}
+
+	// поиск YouTube не различает регистр
+	key := fmt.Sprintf("%s\x00%s\x00%d", strings.ToLower(query.Query), query.PageToken, query.PageSize)
+
+	if page, ok := s.searches.get(key); ok {
+		return page, nil
+	}
+
+	page, shared, err := s.inflight.do(key, func() (*dto.SearchPage, error) {
+		// запрос общий для всех, кто ждет, поэтому отмена первого клиента его не обрывает
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), upstreamTimeout)
+		defer cancel()
+
+		page, err := s.search(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		s.searches.set(key, page)
+		return page, nil
+	})
+	if shared {
+		s.searches.coalesce()
+	}
+
+	return page, err
+}
+
+// search ходит в YouTube за страницей поиска и длительностями найденных видео
+func (s *YouTube) search(ctx context.Context, query dto.SearchQuery) (*dto.SearchPage, error) {
+	// строим запрос на получение url и названия видео
+	// и делаем его первым ключом с квотой
+	response, err := call(s, opSearch, func(yt *youtube.Service) (*youtube.SearchListResponse, error) {
+		return yt.Search.List([]string{id, snippet}).Q(query.Query).Type(typeQuery).
+			MaxResults(query.PageSize).PageToken(query.PageToken).Context(ctx).Do()
+	})
+	if err != nil {
+		// протухший или чужой page_token YouTube отклоняет с 400
+		var apiErr *googleapi.Error
+		if query.PageToken != "" && errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest {
+			return nil, fmt.Errorf("%w: page token is invalid", ErrInvalidPage)
+		}
+		return nil, upstreamError(err)
+	}
+
+	// делаем слайс id для получения продолжительности видео
+	ids := make([]string, len(response.Items))
+	for i, item := range response.Items {
+		ids[i] = item.Id.VideoId
+	}
+
+	page := &dto.SearchPage{
+		Videos:        make([]*dto.Video, len(response.Items)),
+		NextPageToken: response.NextPageToken,
+		PrevPageToken: response.PrevPageToken,
+	}
+
+	if len(ids) == 0 {
+		return page, nil
+	}
+
+	// делаем запрос сразу по всем за длительностью
+	videos, err := s.lookupVideos(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// формируем ответ
+	for i, item := range response.Items {
+		res, ok := videos[item.Id.VideoId]
+		if !ok {
+			res = &dto.Video{Provider: ProviderYouTube, URL: CanonicalURL(item.Id.VideoId), Title: item.Snippet.Title}
+		}
+		page.Videos[i] = res
+	}
+	return page, nil
+}
+
+// Lookup находит видео по ссылке или айди и отдает его с названием и длительностью из YouTube
+func (s *YouTube) Lookup(ctx context.Context, ref string) (*dto.Video, error) {
+	videoID, err := ParseVideoID(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	videos, err := s.lookupVideos(ctx, []string{videoID})
+	if err != nil {
+		return nil, err
+	}
+
+	res, ok := videos[videoID]
+	if !ok {
+		return nil, ErrVideoNotFound
+	}
+
+	return res, nil
+}
+
+// lookupVideos отдает названия и длительности, ключ — айди видео. Чего нет в кеше,
+// добирается одним запросом Videos.List. Удаленных и закрытых видео в ответе нет
+func (s *YouTube) lookupVideos(ctx context.Context, ids []string) (map[string]*dto.Video, error) {
+	videos := make(map[string]*dto.Video, len(ids))
+	missing := make([]string, 0, len(ids))
+
+	for _, videoID := range ids {
+		if video, ok := s.videos.get(videoID); ok {
+			videos[videoID] = video
+			continue
+		}
+		missing = append(missing, videoID)
+	}
+
+	if len(missing) == 0 {
+		return videos, nil
+	}
+
+	fetched, err := s.fetchVideos(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for videoID, video := range fetched {
+		s.videos.set(videoID, video)
+		videos[videoID] = video
+	}
+
+	return videos, nil
+}
+
+func (s *YouTube) fetchVideos(ctx context.Context, ids []string) (map[string]*dto.Video, error) {
+	respVideo, err := call(s, opVideos, func(yt *youtube.Service) (*youtube.VideoListResponse, error) {
+		return yt.Videos.List([]string{snippet, contentDetails}).Id(strings.Join(ids, ",")).Context(ctx).Do()
+	})
+	if err != nil {
+		return nil, upstreamError(err)
+	}
+
+	videos := make(map[string]*dto.Video, len(respVideo.Items))
+
+	// раскидываю таймы по id
+	for _, item := range respVideo.Items {
+		d, err := duration.Parse(item.ContentDetails.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad duration %q: %v", ErrUpstream, item.ContentDetails.Duration, err)
+		}
+
+		videos[item.Id] = &dto.Video{
+			Provider: ProviderYouTube,
+			URL:      CanonicalURL(item.Id),
+			Title:    item.Snippet.Title,
+			Duration: durationOnSecond(d),
+		}
+	}
+
+	return videos, nil
+}
+
+// вспомогательная функция для преобразования в int64
+func durationOnSecond(d *duration.Duration) int64 {
+	return int64(d.Seconds) + (int64(d.Minutes) * 60) + (int64(d.Hours) * 3600)
+}
+
+// CacheStats отдает счетчики кешей поиска и видео
+func (s *YouTube) CacheStats() dto.CacheReport {
+	return dto.CacheReport{Searches: s.searches.stats(), Videos: s.videos.stats()}
+}
@@ -0,0 +1,195 @@
+package room
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"mrs/internal/dto"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// cursor — место, на котором закончилась страница: ключ сортировки и айди последней комнаты
+type cursor struct {
+	Sort string    `json:"s"`
+	Key  int64     `json:"k"`
+	ID   uuid.UUID `json:"i"`
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(raw string) (cursor, error) {
+	var c cursor
+
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// sortKey — по чему сортируем: число слушателей или время создания, по убыванию
+func sortKey(summary *dto.RoomSummary, by string) int64 {
+	if by == dto.SortListeners {
+		return int64(summary.Subscribers)
+	}
+
+	return summary.CreatedAt.UnixNano()
+}
+
+// before — a идет раньше b: больший ключ раньше, при равенстве — по айди
+func before(a, b *dto.RoomSummary, by string) bool {
+	ka, kb := sortKey(a, by), sortKey(b, by)
+	if ka != kb {
+		return ka > kb
+	}
+
+	return a.ID.String() < b.ID.String()
+}
+
+// afterCursor — комната идет после места, где закончилась прошлая страница
+func afterCursor(summary *dto.RoomSummary, c cursor) bool {
+	k := sortKey(summary, c.Sort)
+	if k != c.Key {
+		return k < c.Key
+	}
+
+	return summary.ID.String() > c.ID.String()
+}
+
+func (r *Room) summaryLocked(now time.Time) *dto.RoomSummary {
+	return &dto.RoomSummary{
+		ID:          r.id,
+		Name:        r.settings.Name,
+		Description: r.settings.Description,
+		Private:     r.private,
+		Protected:   r.passwordHash != nil,
+		Subscribers: len(r.subscribers),
+		Playing:     r.playing,
+		Current:     r.current,
+		Position:    r.positionLocked(now),
+		QueueLength: len(r.queue),
+		CreatedAt:   r.createAt,
+	}
+}
+
+// matchLocked проверяет комнату по фильтрам запроса
+func (r *Room) matchLocked(query dto.RoomQuery, meta dto.Meta) bool {
+	if r.private && !r.isMemberLocked(meta) {
+		return false
+	}
+
+	switch query.Visibility {
+	case dto.VisibilityPublic:
+		if r.private {
+			return false
+		}
+	case dto.VisibilityPrivate:
+		if !r.private {
+			return false
+		}
+	}
+
+	if query.Name != "" && !strings.Contains(strings.ToLower(r.settings.Name), strings.ToLower(query.Name)) {
+		return false
+	}
+
+	if query.Playing != nil && r.playing != *query.Playing {
+		return false
+	}
+
+	return true
+}
+
+// ListRooms отдает страницу каталога комнат без очередей.
+// Приватные комнаты видны только тем, кто в них состоит
+func (rs *ServiceRoom) ListRooms(query dto.RoomQuery, meta dto.Meta) (*dto.RoomPage, error) {
+	switch query.Sort {
+	case "":
+		query.Sort = dto.SortCreated
+	case dto.SortCreated, dto.SortListeners:
+	default:
+		return nil, fmt.Errorf("%w: unknown sort %q", ErrInvalidQuery, query.Sort)
+	}
+
+	switch query.Visibility {
+	case "", dto.VisibilityPublic, dto.VisibilityPrivate:
+	default:
+		return nil, fmt.Errorf("%w: unknown visibility %q", ErrInvalidQuery, query.Visibility)
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = defaultPageLimit
+	}
+	if query.Limit > maxPageLimit {
+		query.Limit = maxPageLimit
+	}
+
+	var after *cursor
+	if query.Cursor != "" {
+		c, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != query.Sort {
+			return nil, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidCursor, c.Sort)
+		}
+		after = &c
+	}
+
+	// 1. Делаем срез комнат под локом сервиса
+	rs.mu.RLock()
+	rooms := make([]*Room, 0, len(rs.rooms))
+	for _, room := range rs.rooms {
+		rooms = append(rooms, room)
+	}
+	rs.mu.RUnlock()
+
+	// 2. Под локом каждой комнаты фильтруем и снимаем сводку
+	now := time.Now()
+	summaries := make([]*dto.RoomSummary, 0, len(rooms))
+	for _, room := range rooms {
+		room.mu.RLock()
+		if room.matchLocked(query, meta) {
+			summaries = append(summaries, room.summaryLocked(now))
+		}
+		room.mu.RUnlock()
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return before(summaries[i], summaries[j], query.Sort)
+	})
+
+	// 3. Пропускаем то, что уже было на прошлых страницах
+	start := 0
+	if after != nil {
+		start = sort.Search(len(summaries), func(i int) bool {
+			return afterCursor(summaries[i], *after)
+		})
+	}
+
+	end := min(start+query.Limit, len(summaries))
+
+	page := &dto.RoomPage{Rooms: summaries[start:end]}
+	if end < len(summaries) {
+		last := summaries[end-1]
+		page.NextCursor = encodeCursor(cursor{Sort: query.Sort, Key: sortKey(last, query.Sort), ID: last.ID})
+	}
+
+	return page, nil
+}
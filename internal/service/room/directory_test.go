@@ -0,0 +1,271 @@
+package room
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"mrs/internal/dto"
+	"slices"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2025, 11, 22, 12, 0, 0, 0, time.UTC)
+
+type testRoom struct {
+	name      string
+	created   int // минут после base
+	listeners int
+	private   bool
+	playing   bool
+}
+
+// newDirectory собирает сервис с комнатами без воркера очистки
+func newDirectory(rooms ...testRoom) *ServiceRoom {
+	rs := &ServiceRoom{rooms: make(map[uuid.UUID]*Room)}
+
+	for _, tr := range rooms {
+		room := &Room{
+			id:          uuid.New(),
+			playing:     tr.playing,
+			private:     tr.private,
+			settings:    dto.RoomSettings{Name: tr.name},
+			subscribers: make(map[int]chan dto.State),
+			members:     make(map[int]*member),
+			createAt:    base.Add(time.Duration(tr.created) * time.Minute),
+		}
+		for i := 1; i <= tr.listeners; i++ {
+			room.subscribers[i] = make(chan dto.State, 1)
+		}
+
+		rs.rooms[room.id] = room
+	}
+
+	return rs
+}
+
+// listAll листает каталог до конца и отдает имена комнат по страницам
+func listAll(t *testing.T, rs *ServiceRoom, query dto.RoomQuery) [][]string {
+	t.Helper()
+
+	var pages [][]string
+	for {
+		page, err := rs.ListRooms(query, dto.Meta{})
+		if err != nil {
+			t.Fatalf("ListRooms: %v", err)
+		}
+
+		names := make([]string, len(page.Rooms))
+		for i, summary := range page.Rooms {
+			names[i] = summary.Name
+		}
+		pages = append(pages, names)
+
+		if page.NextCursor == "" {
+			return pages
+		}
+		if len(pages) > 10 {
+			t.Fatal("cursor does not advance")
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func TestListRoomsPages(t *testing.T) {
+	rs := newDirectory(
+		testRoom{name: "a", created: 1, listeners: 3},
+		testRoom{name: "b", created: 2, listeners: 0},
+		testRoom{name: "c", created: 3, listeners: 5},
+		testRoom{name: "d", created: 4, listeners: 1},
+		testRoom{name: "e", created: 5, listeners: 4},
+		testRoom{name: "f", created: 6, listeners: 2},
+		testRoom{name: "g", created: 7, listeners: 6},
+	)
+
+	tests := []struct {
+		name  string
+		query dto.RoomQuery
+		want  [][]string
+	}{
+		{
+			name:  "newest first",
+			query: dto.RoomQuery{Limit: 3},
+			want:  [][]string{{"g", "f", "e"}, {"d", "c", "b"}, {"a"}},
+		},
+		{
+			name:  "most listeners first",
+			query: dto.RoomQuery{Limit: 3, Sort: dto.SortListeners},
+			want:  [][]string{{"g", "c", "e"}, {"a", "f", "d"}, {"b"}},
+		},
+		{
+			name:  "page size equals rooms",
+			query: dto.RoomQuery{Limit: 7},
+			want:  [][]string{{"g", "f", "e", "d", "c", "b", "a"}},
+		},
+		{
+			name:  "filter with one room per page",
+			query: dto.RoomQuery{Limit: 1, Sort: dto.SortListeners, Name: "c"},
+			want:  [][]string{{"c"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := listAll(t, rs, tt.query)
+			if !slices.EqualFunc(got, tt.want, slices.Equal[[]string]) {
+				t.Errorf("pages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// одинаковое число слушателей: порядок и продолжение страниц держатся на айди комнаты
+func TestListRoomsTies(t *testing.T) {
+	rooms := make([]testRoom, 10)
+	for i := range rooms {
+		rooms[i] = testRoom{name: string(rune('a' + i)), created: i, listeners: 2}
+	}
+	rs := newDirectory(rooms...)
+
+	pages := listAll(t, rs, dto.RoomQuery{Limit: 3, Sort: dto.SortListeners})
+
+	seen := make(map[string]bool)
+	for _, page := range pages {
+		for _, name := range page {
+			if seen[name] {
+				t.Fatalf("room %s is on two pages: %v", name, pages)
+			}
+			seen[name] = true
+		}
+	}
+
+	if len(seen) != len(rooms) {
+		t.Errorf("listed %d rooms, want %d: %v", len(seen), len(rooms), pages)
+	}
+}
+
+// комната с прошлой страницы удалена, курсор все равно продолжает с нужного места
+func TestListRoomsCursorAfterRemove(t *testing.T) {
+	rs := newDirectory(
+		testRoom{name: "a", created: 1},
+		testRoom{name: "b", created: 2},
+		testRoom{name: "c", created: 3},
+		testRoom{name: "d", created: 4},
+	)
+
+	first, err := rs.ListRooms(dto.RoomQuery{Limit: 2}, dto.Meta{})
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+
+	rs.RemoveRoom(first.Rooms[1].ID)
+
+	second, err := rs.ListRooms(dto.RoomQuery{Limit: 2, Cursor: first.NextCursor}, dto.Meta{})
+	if err != nil {
+		t.Fatalf("second page: %v", err)
+	}
+
+	var got []string
+	for _, summary := range second.Rooms {
+		got = append(got, summary.Name)
+	}
+
+	if want := []string{"b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("second page = %v, want %v", got, want)
+	}
+}
+
+func TestListRoomsFilters(t *testing.T) {
+	rs := newDirectory(
+		testRoom{name: "Rock night", created: 1, playing: true},
+		testRoom{name: "jazz", created: 2},
+		testRoom{name: "rock hidden", created: 3, private: true, playing: true},
+		testRoom{name: "lofi", created: 4, playing: true},
+	)
+
+	playing, stopped := true, false
+
+	tests := []struct {
+		name  string
+		query dto.RoomQuery
+		want  []string
+	}{
+		{
+			name: "private rooms hidden from strangers",
+			want: []string{"lofi", "jazz", "Rock night"},
+		},
+		{
+			name:  "public only",
+			query: dto.RoomQuery{Visibility: dto.VisibilityPublic},
+			want:  []string{"lofi", "jazz", "Rock night"},
+		},
+		{
+			name:  "private only for strangers",
+			query: dto.RoomQuery{Visibility: dto.VisibilityPrivate},
+			want:  []string{},
+		},
+		{
+			name:  "name ignores case",
+			query: dto.RoomQuery{Name: "ROCK"},
+			want:  []string{"Rock night"},
+		},
+		{
+			name:  "playing",
+			query: dto.RoomQuery{Playing: &playing},
+			want:  []string{"lofi", "Rock night"},
+		},
+		{
+			name:  "stopped",
+			query: dto.RoomQuery{Playing: &stopped},
+			want:  []string{"jazz"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := rs.ListRooms(tt.query, dto.Meta{})
+			if err != nil {
+				t.Fatalf("ListRooms: %v", err)
+			}
+
+			got := make([]string, len(page.Rooms))
+			for i, summary := range page.Rooms {
+				got[i] = summary.Name
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("rooms = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListRoomsErrors(t *testing.T) {
+	rs := newDirectory(
+		testRoom{name: "a", created: 1},
+		testRoom{name: "b", created: 2},
+	)
+
+	page, err := rs.ListRooms(dto.RoomQuery{Limit: 1}, dto.Meta{})
+	if err != nil {
+		t.Fatalf("ListRooms: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query dto.RoomQuery
+		want  error
+	}{
+		{"broken cursor", dto.RoomQuery{Cursor: "not a cursor"}, ErrInvalidCursor},
+		{"cursor of another sort", dto.RoomQuery{Sort: dto.SortListeners, Cursor: page.NextCursor}, ErrInvalidCursor},
+		{"unknown sort", dto.RoomQuery{Sort: "name"}, ErrInvalidQuery},
+		{"unknown visibility", dto.RoomQuery{Visibility: "hidden"}, ErrInvalidQuery},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := rs.ListRooms(tt.query, dto.Meta{}); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package room
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ошибки сервиса комнат, транспорт подбирает по ним статус и код ответа
+var (
+	ErrRoomNotFound  = errors.New("room does not exist")
+	ErrEntryNotFound = errors.New("queue entry does not exist")
+	ErrUserNotFound  = errors.New("user does not exist")
+
+	ErrQueueEmpty      = errors.New("queue is empty")
+	ErrNoCurrentTrack  = errors.New("no current track")
+	ErrHistoryEmpty    = errors.New("history is empty")
+	ErrVersionConflict = errors.New("room state has changed, version is stale")
+	ErrQueueLimit      = errors.New("queue limit reached")
+
+	// тот же конфликт версий, но версия пришла в If-Match
+	ErrPreconditionFailed = fmt.Errorf("%w: If-Match does not match", ErrVersionConflict)
+
+	ErrForbidden     = errors.New("permission denied")
+	ErrWrongPassword = errors.New("wrong room password")
+
+	ErrInvalidSettings   = errors.New("invalid room settings")
+	ErrInvalidQuery      = errors.New("invalid room query")
+	ErrInvalidCursor     = errors.New("invalid cursor")
+	ErrInvalidRepeatMode = errors.New("unknown repeat mode")
+	ErrInvalidRole       = errors.New("invalid role")
+	ErrIndexOutOfRange   = errors.New("index out of range")
+
+	// нарушения правил комнаты (dto.Policy)
+	ErrDuplicateTrack  = errors.New("track is already in the queue")
+	ErrTrackTooLong    = errors.New("track is too long")
+	ErrUnknownDuration = errors.New("track duration is unknown")
+	ErrQueueTooLong    = errors.New("queue total duration limit reached")
+	ErrReplayCooldown  = errors.New("track was played too recently")
+)
@@ -0,0 +1,110 @@
+package room
+
+import (
+	"fmt"
+	"mrs/internal/dto"
+	"slices"
+	"time"
+)
+
+// enqueueLocked добавляет трек в конец очереди, а в честном режиме — в очередной «круг» его автора
+func (r *Room) enqueueLocked(entry *dto.QueueEntry) error {
+	limit := r.settings.UserLimit
+	if limit > 0 && r.pendingLocked(entry.AddedBy) >= limit {
+		return fmt.Errorf("%w: no more than %d pending tracks per user", ErrQueueLimit, limit)
+	}
+
+	if !r.settings.FairQueue {
+		r.queue = append(r.queue, entry)
+		return nil
+	}
+
+	r.queue = fairInsert(r.queue, entry)
+	return nil
+}
+
+// pendingLocked считает, сколько треков автора ждут в очереди
+func (r *Room) pendingLocked(addedBy int) int {
+	n := 0
+	for _, entry := range r.queue {
+		if entry.AddedBy == addedBy {
+			n++
+		}
+	}
+
+	return n
+}
+
+// fairInsert вставляет трек по кругу между авторами: k-й трек автора встает
+// после всех треков, которые в своих авторов идут k-ми или раньше.
+// Порядок остальных треков не меняется
+func fairInsert(queue []*dto.QueueEntry, entry *dto.QueueEntry) []*dto.QueueEntry {
+	seen := make(map[int]int)
+	round := 0
+	for _, e := range queue {
+		if e.AddedBy == entry.AddedBy {
+			round++
+		}
+	}
+
+	pos := 0
+	for i, e := range queue {
+		if seen[e.AddedBy] <= round {
+			pos = i + 1
+		}
+		seen[e.AddedBy]++
+	}
+
+	return slices.Insert(queue, pos, entry)
+}
+
+// checkPolicyLocked проверяет трек по правилам комнаты перед добавлением в очередь
+func (r *Room) checkPolicyLocked(video *dto.Video, now time.Time) error {
+	p := r.settings.Policy
+
+	if p.MaxTrackDuration > 0 || p.MaxQueueDuration > 0 {
+		// у трансляций длительность 0 — с ограничением по времени их не пускаем
+		if video.Duration <= 0 {
+			return fmt.Errorf("%w: live streams are not allowed in this room", ErrUnknownDuration)
+		}
+	}
+
+	if p.MaxTrackDuration > 0 && video.Duration > p.MaxTrackDuration {
+		return fmt.Errorf("%w: %d seconds, limit is %d", ErrTrackTooLong, video.Duration, p.MaxTrackDuration)
+	}
+
+	if p.MaxQueueDuration > 0 {
+		total := video.Duration
+		for _, entry := range r.queue {
+			total += entry.Duration
+		}
+		if total > p.MaxQueueDuration {
+			return fmt.Errorf("%w: limit is %d seconds", ErrQueueTooLong, p.MaxQueueDuration)
+		}
+	}
+
+	if p.RejectDuplicates {
+		if r.current != nil && r.current.URL == video.URL {
+			return fmt.Errorf("%w: it is playing now", ErrDuplicateTrack)
+		}
+		for _, entry := range r.queue {
+			if entry.URL == video.URL {
+				return ErrDuplicateTrack
+			}
+		}
+	}
+
+	if p.ReplayCooldown > 0 {
+		since := now.Add(-time.Duration(p.ReplayCooldown) * time.Minute)
+		if r.current != nil && r.current.URL == video.URL && r.playedAt.After(since) {
+			return fmt.Errorf("%w: wait %d minutes after it started", ErrReplayCooldown, p.ReplayCooldown)
+		}
+		for _, played := range r.history {
+			if played.URL == video.URL && played.StartedAt.After(since) {
+				return fmt.Errorf("%w: wait %d minutes after it started", ErrReplayCooldown, p.ReplayCooldown)
+			}
+		}
+	}
+
+	return nil
+}
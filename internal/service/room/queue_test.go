@@ -0,0 +1,151 @@
+package room
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"mrs/internal/dto"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// newEntry — трек автора addedBy, в названии автор и номер его трека: "1/2"
+func newEntry(addedBy, n int) *dto.QueueEntry {
+	title := strconv.Itoa(addedBy) + "/" + strconv.Itoa(n)
+	return &dto.QueueEntry{ID: uuid.New(), AddedBy: addedBy, Video: dto.Video{URL: title, Title: title, Duration: 60}}
+}
+
+// titles — порядок очереди в виде названий треков
+func titles(queue []*dto.QueueEntry) []string {
+	res := make([]string, len(queue))
+	for i, entry := range queue {
+		res[i] = entry.Title
+	}
+
+	return res
+}
+
+func TestFairInsert(t *testing.T) {
+	tests := []struct {
+		name  string
+		added []int // авторы в порядке добавления
+		want  []string
+	}{
+		{
+			name:  "one contributor keeps order",
+			added: []int{1, 1, 1},
+			want:  []string{"1/1", "1/2", "1/3"},
+		},
+		{
+			name:  "two contributors alternate",
+			added: []int{1, 1, 1, 2, 2},
+			want:  []string{"1/1", "2/1", "1/2", "2/2", "1/3"},
+		},
+		{
+			name:  "three contributors go round by round",
+			added: []int{1, 1, 1, 2, 3, 3},
+			want:  []string{"1/1", "2/1", "3/1", "1/2", "3/2", "1/3"},
+		},
+		{
+			name:  "late contributor joins the current round",
+			added: []int{1, 2, 1, 2, 3},
+			want:  []string{"1/1", "2/1", "3/1", "1/2", "2/2"},
+		},
+		{
+			name:  "added outside the room is a contributor too",
+			added: []int{0, 0, 5},
+			want:  []string{"0/1", "5/1", "0/2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queue []*dto.QueueEntry
+			count := make(map[int]int)
+			for _, addedBy := range tt.added {
+				count[addedBy]++
+				queue = fairInsert(queue, newEntry(addedBy, count[addedBy]))
+			}
+
+			if got := titles(queue); !slices.Equal(got, tt.want) {
+				t.Errorf("queue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnqueueUserLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		fair     bool
+		limit    int
+		added    []int
+		want     []string
+		rejected int
+	}{
+		{
+			name:  "no limit",
+			added: []int{1, 1, 1},
+			want:  []string{"1/1", "1/2", "1/3"},
+		},
+		{
+			name:     "limit rejects extra tracks of one user",
+			limit:    2,
+			added:    []int{1, 1, 1, 2},
+			want:     []string{"1/1", "1/2", "2/1"},
+			rejected: 1,
+		},
+		{
+			name:     "limit with fair queue",
+			fair:     true,
+			limit:    2,
+			added:    []int{1, 1, 1, 2, 2, 2},
+			want:     []string{"1/1", "2/1", "1/2", "2/2"},
+			rejected: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Room{settings: dto.RoomSettings{FairQueue: tt.fair, UserLimit: tt.limit}}
+
+			rejected := 0
+			count := make(map[int]int)
+			for _, addedBy := range tt.added {
+				count[addedBy]++
+				err := r.enqueueLocked(newEntry(addedBy, count[addedBy]))
+				if err != nil {
+					if !errors.Is(err, ErrQueueLimit) {
+						t.Fatalf("enqueue: %v, want %v", err, ErrQueueLimit)
+					}
+					rejected++
+				}
+			}
+
+			if got := titles(r.queue); !slices.Equal(got, tt.want) {
+				t.Errorf("queue = %v, want %v", got, tt.want)
+			}
+			if rejected != tt.rejected {
+				t.Errorf("rejected = %d, want %d", rejected, tt.rejected)
+			}
+		})
+	}
+}
+
+func TestEnqueueLimitFreesAfterPlay(t *testing.T) {
+	r := &Room{settings: dto.RoomSettings{UserLimit: 1}}
+
+	if err := r.enqueueLocked(newEntry(1, 1)); err != nil {
+		t.Fatalf("first track: %v", err)
+	}
+	if err := r.enqueueLocked(newEntry(1, 2)); !errors.Is(err, ErrQueueLimit) {
+		t.Fatalf("second track: %v, want %v", err, ErrQueueLimit)
+	}
+
+	// трек ушел из очереди в плеер — место у автора освободилось
+	r.queue = r.queue[1:]
+
+	if err := r.enqueueLocked(newEntry(1, 3)); err != nil {
+		t.Fatalf("track after play: %v", err)
+	}
+}
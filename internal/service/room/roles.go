@@ -0,0 +1,222 @@
+package room
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"mrs/internal/dto"
+	"sort"
+	"time"
+)
+
+// member — подключение к комнате и его роль
+type member struct {
+	role  string // dto.RoleOwner / RoleDJ / RoleListener
+	token string // секрет подключения, по нему REST-вызовы действуют от его имени
+}
+
+// ранги ролей: чем больше, тем больше прав
+var roleRank = map[string]int{
+	dto.RoleListener: 0,
+	dto.RoleDJ:       1,
+	dto.RoleOwner:    2,
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func tokenEqual(a, b string) bool {
+	return a != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// lookupLocked ищет вызывающего среди участников комнаты:
+// подписчик по айди, владелец по секрету комнаты, подписчик по своему токену
+func (r *Room) lookupLocked(meta dto.Meta) (string, bool) {
+	if m, ok := r.members[meta.UserID]; ok {
+		return m.role, true
+	}
+
+	if tokenEqual(meta.Token, r.ownerToken) {
+		return dto.RoleOwner, true
+	}
+
+	if meta.Token != "" {
+		for _, m := range r.members {
+			if tokenEqual(meta.Token, m.token) {
+				return m.role, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// contributorLocked — айди подключения, от имени которого идет вызов; 0 — вызов не от подключения
+func (r *Room) contributorLocked(meta dto.Meta) int {
+	if _, ok := r.members[meta.UserID]; ok {
+		return meta.UserID
+	}
+
+	if meta.Token != "" {
+		for userID, m := range r.members {
+			if tokenEqual(meta.Token, m.token) {
+				return userID
+			}
+		}
+	}
+
+	return 0
+}
+
+// roleLocked определяет роль того, кто делает вызов, не участник — слушатель
+func (r *Room) roleLocked(meta dto.Meta) string {
+	if role, ok := r.lookupLocked(meta); ok {
+		return role
+	}
+
+	return dto.RoleListener
+}
+
+// isMemberLocked — вызывающий владелец комнаты или одно из ее подключений
+func (r *Room) isMemberLocked(meta dto.Meta) bool {
+	_, ok := r.lookupLocked(meta)
+	return ok
+}
+
+// passwordMatches сверяет пароль с хешем. Хеш не меняется после создания комнаты, лок не нужен
+func (r *Room) passwordMatches(password string) bool {
+	if r.passwordHash == nil {
+		return true
+	}
+
+	return password != "" && bcrypt.CompareHashAndPassword(r.passwordHash, []byte(password)) == nil
+}
+
+// accessRoom отдает комнату, если вызывающему можно в нее: комната без пароля,
+// вызывающий уже в ней состоит (владелец или подключение, которое вошло с паролем) или пароль верный.
+// bcrypt считается вне лока комнаты, чтобы не тормозить остальных
+func (rs *ServiceRoom) accessRoom(id uuid.UUID, meta dto.Meta) (*Room, error) {
+	room, err := rs.getRoom(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if room.passwordHash == nil {
+		return room, nil
+	}
+
+	room.mu.RLock()
+	member := room.isMemberLocked(meta)
+	room.mu.RUnlock()
+
+	if !member && !room.passwordMatches(meta.Password) {
+		return nil, ErrWrongPassword
+	}
+
+	return room, nil
+}
+
+// requireLocked проверяет, что у вызывающего есть как минимум роль role
+func (r *Room) requireLocked(meta dto.Meta, role string) error {
+	if roleRank[r.roleLocked(meta)] < roleRank[role] {
+		return fmt.Errorf("%w: %s role required", ErrForbidden, role)
+	}
+
+	return nil
+}
+
+// requireMemberLocked проверяет, что вызывающий — владелец или одно из подключений комнаты
+func (r *Room) requireMemberLocked(meta dto.Meta) error {
+	if !r.isMemberLocked(meta) {
+		return fmt.Errorf("%w: room membership required", ErrForbidden)
+	}
+
+	return nil
+}
+
+func (r *Room) membersLocked() []dto.Member {
+	members := make([]dto.Member, 0, len(r.members))
+	for userID, m := range r.members {
+		members = append(members, dto.Member{UserID: userID, Role: m.role})
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].UserID < members[j].UserID
+	})
+
+	return members
+}
+
+// Authorize заранее проверяет, что вызывающему можно в комнату и что у него есть роль role,
+// а переданная версия актуальна. Транспорт зовет ее до похода к провайдеру музыки, чтобы посторонние
+// не тратили квоту запросами, которые все равно будут отклонены. Саму команду сервис проверяет еще раз
+func (rs *ServiceRoom) Authorize(id uuid.UUID, meta dto.Meta, role string) error {
+	room, err := rs.accessRoom(id, meta)
+	if err != nil {
+		return err
+	}
+
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+
+	if err := room.requireMemberLocked(meta); err != nil {
+		return err
+	}
+
+	if err := room.requireLocked(meta, role); err != nil {
+		return err
+	}
+
+	return room.checkVersionLocked(meta)
+}
+
+// SetRole меняет роль подключения: владелец может назначить диджея или вернуть его в слушатели
+func (rs *ServiceRoom) SetRole(id uuid.UUID, userID int, role string, meta dto.Meta) error {
+	if role != dto.RoleDJ && role != dto.RoleListener {
+		return fmt.Errorf("%w: role must be %s or %s", ErrInvalidRole, dto.RoleDJ, dto.RoleListener)
+	}
+
+	room, err := rs.getRoom(id)
+	if err != nil {
+		return err
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if err := room.requireLocked(meta, dto.RoleOwner); err != nil {
+		return err
+	}
+
+	if err := room.checkVersionLocked(meta); err != nil {
+		return err
+	}
+
+	m, ok := room.members[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	if m.role == dto.RoleOwner {
+		return fmt.Errorf("%w: owner role cannot be changed", ErrForbidden)
+	}
+
+	if m.role == role {
+		return nil
+	}
+
+	m.role = role
+
+	room.commitLocked(time.Now())
+
+	return nil
+}
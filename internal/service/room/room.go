@@ -2,46 +2,69 @@ package room
 
 import (
 	"github.com/google/uuid"
+	"math"
 	"mrs/internal/dto"
 	"sync"
 	"time"
 )
 
+// сколько сыгранных треков помним в истории комнаты
+const historyLimit = 50
+
 type Room struct {
 	mu        sync.RWMutex
-	id        uuid.UUID    // айди комнаты
-	queue     []*dto.Video // очередь видео
-	current   *dto.Video   // текущее видео
-	playing   bool         // флаг того, что играет
-	basePos   float64      // время видео для синхронизации
-	startedAt time.Time    // время начала действия какого то
+	id        uuid.UUID         // айди комнаты
+	queue     []*dto.QueueEntry // очередь видео
+	current   *dto.QueueEntry   // текущее видео
+	playing   bool              // флаг того, что играет
+	repeat    string            // режим повтора (dto.RepeatOff/One/All)
+	basePos   float64           // время видео для синхронизации
+	startedAt time.Time         // время начала действия какого то
+	playedAt  time.Time         // когда текущий трек начал играть
+
+	history []*dto.HistoryEntry // сыгранные треки, последний — в конце
 
 	subscribers map[int]chan dto.State // пользователи
 	nextSubID   int                    // айди для пользоввателй
 
+	ownerToken string          // секрет владельца комнаты
+	members    map[int]*member // роли подключений
+
+	// задаются при создании и больше не меняются, поэтому читаются без лока
+	passwordHash []byte // bcrypt-хеш пароля, nil — комната без пароля
+	private      bool   // не показывать комнату в списке тем, кто в ней не состоит
+
+	version uint64 // растет на каждое изменение state, кроме входа и выхода участников
+
+	settings dto.RoomSettings // имя, описание, голосование, честная очередь и правила
+
+	votes map[int]struct{} // кто проголосовал за пропуск текущего трека
+
+	timer    *time.Timer // таймер конца текущего трека
+	timerGen uint64      // поколение таймера, чтобы отсеять устаревшие срабатывания
+
 	createAt time.Time
 }
 
 // слепок который отдаем пользователям он к ним привязан
 
+// broadcastLocked рассылает state подписчикам. Кто не успевает читать, отключается целиком:
+// вместе с подпиской уходят его роль и голос, иначе он остался бы в участниках навсегда
 func (r *Room) broadcastLocked(state dto.State) {
 	for userID, ch := range r.subscribers {
 		select {
 		case ch <- state:
 		default:
 			delete(r.subscribers, userID)
+			delete(r.members, userID)
+			delete(r.votes, userID)
 			close(ch)
 		}
 	}
 }
 
 func (r *Room) stateLock(now time.Time) dto.State {
-	pos := r.basePos
-	if r.playing {
-		pos += now.Sub(r.startedAt).Seconds()
-	}
-
-	q := make([]*dto.Video, len(r.queue))
+	q := make([]*dto.QueueEntry, len(r.queue))
 	copy(q, r.queue)
 
 	return dto.State{
@@ -49,7 +72,196 @@ func (r *Room) stateLock(now time.Time) dto.State {
 		Current:   r.current,
 		Queue:     q,
 		Playing:   r.playing,
-		Position:  pos,
+		Repeat:    r.repeat,
+		Version:   r.version,
+		Position:  r.positionLocked(now),
 		UpdatedAt: now,
+
+		Votes:       r.votesLocked(),
+		VotesNeeded: r.votesNeededLocked(),
+
+		Members: r.membersLocked(),
+
+		Settings:  r.settings,
+		CreatedAt: r.createAt,
 	}
 }
+
+// votesLocked считает голоса только тех, кто еще подключен к комнате
+func (r *Room) votesLocked() int {
+	n := 0
+	for userID := range r.votes {
+		if _, ok := r.subscribers[userID]; ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (r *Room) votesNeededLocked() int {
+	if !r.settings.VoteSkip {
+		return 0
+	}
+
+	need := int(math.Ceil(r.settings.VoteShare * float64(len(r.subscribers))))
+	if need < 1 {
+		need = 1
+	}
+
+	return need
+}
+
+// skipVotedLocked пропускает трек, если голосов уже достаточно
+func (r *Room) skipVotedLocked(now time.Time) bool {
+	if !r.settings.VoteSkip || r.current == nil {
+		return false
+	}
+
+	votes := r.votesLocked()
+	if votes == 0 || votes < r.votesNeededLocked() {
+		return false
+	}
+
+	r.nextLocked(now, false)
+	return true
+}
+
+// indexOfLocked ищет трек в очереди по его айди
+func (r *Room) indexOfLocked(entryID uuid.UUID) (int, error) {
+	for i, entry := range r.queue {
+		if entry.ID == entryID {
+			return i, nil
+		}
+	}
+
+	return -1, ErrEntryNotFound
+}
+
+func (r *Room) positionLocked(now time.Time) float64 {
+	pos := r.basePos
+	if r.playing {
+		pos += now.Sub(r.startedAt).Seconds()
+	}
+
+	return pos
+}
+
+// nextLocked переключает комнату на следующий трек из очереди с учетом режима повтора,
+// если очередь пуста — останавливает воспроизведение.
+// ended — трек доиграл сам, а не был пропущен пользователем
+func (r *Room) nextLocked(now time.Time, ended bool) {
+	r.basePos = 0
+	r.startedAt = now
+	clear(r.votes)
+
+	// повтор одного трека: прошлое проигрывание уходит в историю, новое начинается сейчас
+	if ended && r.repeat == dto.RepeatOne && r.current != nil {
+		r.setCurrentLocked(r.current, now)
+		r.playing = true
+		return
+	}
+
+	// доигравший трек уходит в историю, а в конец очереди встает его копия с новым айди,
+	// чтобы айди в очереди не совпадали с айди в истории и текущего трека
+	if r.repeat == dto.RepeatAll && r.current != nil {
+		requeued := *r.current
+		requeued.ID = uuid.New()
+		r.queue = append(r.queue, &requeued)
+	}
+
+	if len(r.queue) == 0 {
+		r.setCurrentLocked(nil, now)
+		r.playing = false
+		return
+	}
+
+	r.setCurrentLocked(r.queue[0], now)
+	r.queue = r.queue[1:]
+	r.playing = true
+}
+
+// setCurrentLocked меняет текущий трек, а доигравший отправляет в историю
+func (r *Room) setCurrentLocked(entry *dto.QueueEntry, now time.Time) {
+	if r.current != nil {
+		r.history = append(r.history, &dto.HistoryEntry{QueueEntry: *r.current, StartedAt: r.playedAt})
+		if len(r.history) > historyLimit {
+			r.history = r.history[len(r.history)-historyLimit:]
+		}
+	}
+
+	r.current = entry
+	r.playedAt = now
+	clear(r.votes)
+}
+
+// checkVersionLocked сверяет версию, которую видел клиент, с текущей.
+// 0 — клиент версию не передал, проверка не нужна
+func (r *Room) checkVersionLocked(meta dto.Meta) error {
+	if meta.Version == 0 || meta.Version == r.version {
+		return nil
+	}
+
+	if meta.IfMatch {
+		return ErrPreconditionFailed
+	}
+
+	return ErrVersionConflict
+}
+
+// commitLocked поднимает версию, перепланирует таймер конца трека и рассылает новый state
+func (r *Room) commitLocked(now time.Time) {
+	r.version++
+	r.scheduleLocked(now)
+
+	st := r.stateLock(now)
+	r.broadcastLocked(st)
+}
+
+// notifyLocked рассылает state без новой версии: так расходятся изменения, на которые
+// не должны натыкаться команды с ожидаемой версией, например вход и выход слушателей
+func (r *Room) notifyLocked(now time.Time) {
+	r.broadcastLocked(r.stateLock(now))
+}
+
+// scheduleLocked ставит таймер на момент, когда текущий трек должен закончиться
+func (r *Room) scheduleLocked(now time.Time) {
+	r.stopTimerLocked()
+
+	if !r.playing || r.current == nil || r.current.Duration <= 0 {
+		return
+	}
+
+	left := float64(r.current.Duration) - r.positionLocked(now)
+	if left < 0 {
+		left = 0
+	}
+
+	gen := r.timerGen
+	r.timer = time.AfterFunc(time.Duration(left*float64(time.Second)), func() {
+		r.onTrackEnd(gen)
+	})
+}
+
+func (r *Room) stopTimerLocked() {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	r.timerGen++
+}
+
+// onTrackEnd срабатывает по таймеру и делает то же самое, что и Next
+func (r *Room) onTrackEnd(gen uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// за время ожидания state успел поменяться — таймер уже не актуален
+	if gen != r.timerGen {
+		return
+	}
+
+	now := time.Now()
+	r.nextLocked(now, true)
+	r.commitLocked(now)
+}
@@ -3,7 +3,9 @@ package room
 import (
 	"fmt"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 	"mrs/internal/dto"
+	"slices"
 	"sync"
 	"time"
 )
@@ -20,18 +22,46 @@ func NewServiceRoom(interval, idle time.Duration) *ServiceRoom {
 	return serviceRoom
 }
 
-func (rs *ServiceRoom) CreateRoom() (uuid.UUID, error) {
+// CreateRoom создает комнату и возвращает ее айди и секрет владельца.
+// Пароль хранится только в виде bcrypt-хеша
+func (rs *ServiceRoom) CreateRoom(req dto.CreateRoomRequest) (uuid.UUID, string, error) {
+	settings := defaultSettings()
+	if err := applySettings(&settings, req.RoomSettingsPatch); err != nil {
+		return uuid.Nil, "", err
+	}
+
+	ownerToken, err := newToken()
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	var passwordHash []byte
+	if req.Password != "" {
+		passwordHash, err = bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return uuid.Nil, "", err
+		}
+	}
+
 	rs.mu.Lock()
 	defer rs.mu.Unlock()
 	id := uuid.New()
 	rs.rooms[id] = &Room{
-		id:          id,
-		queue:       make([]*dto.Video, 0, 100),
-		subscribers: make(map[int]chan dto.State),
-		nextSubID:   1,
-		createAt:    time.Now(),
+		id:           id,
+		queue:        make([]*dto.QueueEntry, 0, 100),
+		repeat:       dto.RepeatOff,
+		version:      1,
+		settings:     settings,
+		votes:        make(map[int]struct{}),
+		subscribers:  make(map[int]chan dto.State),
+		nextSubID:    1,
+		ownerToken:   ownerToken,
+		members:      make(map[int]*member),
+		passwordHash: passwordHash,
+		private:      req.Private,
+		createAt:     time.Now(),
 	}
-	return id, nil
+	return id, ownerToken, nil
 }
 
 func (rs *ServiceRoom) RemoveRoom(id uuid.UUID) {
@@ -45,8 +75,9 @@ func (rs *ServiceRoom) RemoveRoom(id uuid.UUID) {
 	delete(rs.rooms, id)
 	rs.mu.Unlock()
 
-	// 2. Под локом самой комнаты закрываем всех подписчиков
+	// 2. Под локом самой комнаты гасим таймер и закрываем всех подписчиков
 	room.mu.Lock()
+	room.stopTimerLocked()
 	for userID, ch := range room.subscribers {
 		delete(room.subscribers, userID)
 		close(ch)
@@ -54,10 +85,22 @@ func (rs *ServiceRoom) RemoveRoom(id uuid.UUID) {
 	room.mu.Unlock()
 }
 
-func (rs *ServiceRoom) ConnectToTheRoom(id uuid.UUID) (int, <-chan dto.State, error) {
+// ConnectToTheRoom подписывает подключение на state комнаты.
+// С секретом владельца в meta.Token подключение получает роль владельца, иначе — слушателя.
+// В комнату с паролем без секрета владельца пускаем только с верным meta.Password
+func (rs *ServiceRoom) ConnectToTheRoom(id uuid.UUID, meta dto.Meta) (*dto.Session, <-chan dto.State, error) {
 	room, err := rs.getRoom(id)
 	if err != nil {
-		return -1, nil, err
+		return nil, nil, err
+	}
+
+	if !tokenEqual(meta.Token, room.ownerToken) && !room.passwordMatches(meta.Password) {
+		return nil, nil, ErrWrongPassword
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, nil, err
 	}
 
 	room.mu.Lock()
@@ -66,18 +109,20 @@ func (rs *ServiceRoom) ConnectToTheRoom(id uuid.UUID) (int, <-chan dto.State, er
 	userID := room.nextSubID
 	room.nextSubID++
 
+	role := dto.RoleListener
+	if tokenEqual(meta.Token, room.ownerToken) {
+		role = dto.RoleOwner
+	}
+
 	ch := make(chan dto.State, 10)
 	room.subscribers[userID] = ch
+	room.members[userID] = &member{role: role, token: token}
 
-	// считаем актуальное состояние
-	now := time.Now()
-
-	st := room.stateLock(now)
+	// рассылаем актуальное состояние: новому подписчику это первая отправка,
+	// остальным — обновленный список участников. Версию вход не меняет
+	room.notifyLocked(time.Now())
 
-	// первая отправка
-	ch <- st
-
-	return userID, ch, nil
+	return &dto.Session{Type: "session", UserID: userID, Role: role, Token: token}, ch, nil
 }
 
 func (rs *ServiceRoom) DisconnectUser(id uuid.UUID, userID int) error {
@@ -90,149 +135,405 @@ func (rs *ServiceRoom) DisconnectUser(id uuid.UUID, userID int) error {
 	room.mu.Lock()
 	defer room.mu.Unlock()
 
-	// комнату нашли удаляем юзера
-	ch, ok := room.subscribers[userID]
-	if !ok {
-		return fmt.Errorf("user does not exist")
+	// комнату нашли удаляем юзера. Подписку могли уже снять за медленное чтение,
+	// тогда убираем то, что от подключения осталось
+	ch, subscribed := room.subscribers[userID]
+	_, joined := room.members[userID]
+	if !subscribed && !joined {
+		return ErrUserNotFound
 	}
 
-	delete(room.subscribers, userID)
-	close(ch)
+	if subscribed {
+		delete(room.subscribers, userID)
+		close(ch)
+	}
+	delete(room.members, userID)
+	delete(room.votes, userID)
 
 	if len(room.subscribers) == 0 {
 		go rs.RemoveRoom(id)
+		return nil
+	}
+
+	// ушедший больше не голосует, порог пересчитывается от оставшихся, а список участников меняется.
+	// Версия растет, только если без него набрались голоса и трек сменился
+	now := time.Now()
+	if room.skipVotedLocked(now) {
+		room.commitLocked(now)
+	} else {
+		room.notifyLocked(now)
 	}
 
 	return nil
 }
 
-func (rs *ServiceRoom) Play(id uuid.UUID) error {
-	room, err := rs.getRoom(id)
+// Play запускает воспроизведение: продолжает текущий трек или берет первый из очереди
+func (rs *ServiceRoom) Play(id uuid.UUID, meta dto.Meta) (dto.State, error) {
+	room, err := rs.accessRoom(id, meta)
 	if err != nil {
-		return err
+		return dto.State{}, err
 	}
 
 	room.mu.Lock()
 	defer room.mu.Unlock()
 
+	if err := room.requireLocked(meta, dto.RoleDJ); err != nil {
+		return dto.State{}, err
+	}
+
+	if err := room.checkVersionLocked(meta); err != nil {
+		return dto.State{}, err
+	}
+
+	now := time.Now()
+
 	if room.playing {
-		return nil
+		return room.stateLock(now), nil
 	}
 
 	if room.current == nil {
 		if len(room.queue) == 0 {
-			return fmt.Errorf("queue is empty")
+			return dto.State{}, ErrQueueEmpty
 		}
 		room.basePos = 0
-		room.current = room.queue[0]
+		room.setCurrentLocked(room.queue[0], now)
 		room.queue = room.queue[1:]
 	}
 
-	now := time.Now()
 	room.startedAt = now
 	room.playing = true
 
-	st := room.stateLock(now)
-	room.broadcastLocked(st)
-	return nil
+	room.commitLocked(now)
+	return room.stateLock(now), nil
 }
 
-func (rs *ServiceRoom) Pause(id uuid.UUID) error {
-	room, err := rs.getRoom(id)
+func (rs *ServiceRoom) Pause(id uuid.UUID, meta dto.Meta) (dto.State, error) {
+	room, err := rs.accessRoom(id, meta)
 	if err != nil {
-		return err
+		return dto.State{}, err
 	}
 
 	room.mu.Lock()
 	defer room.mu.Unlock()
 
+	if err := room.requireLocked(meta, dto.RoleDJ); err != nil {
+		return dto.State{}, err
+	}
+
+	if err := room.checkVersionLocked(meta); err != nil {
+		return dto.State{}, err
+	}
+
+	if room.current == nil {
+		return dto.State{}, ErrNoCurrentTrack
+	}
+
+	now := time.Now()
+
 	if !room.playing {
-		return nil
+		return room.stateLock(now), nil
+	}
+
+	room.basePos = room.positionLocked(now)
+	room.startedAt = now
+	room.playing = false
+
+	room.commitLocked(now)
+
+	return room.stateLock(now), nil
+}
+
+// Stop останавливает воспроизведение и перематывает текущий трек в начало
+func (rs *ServiceRoom) Stop(id uuid.UUID, meta dto.Meta) (dto.State, error) {
+	room, err := rs.accessRoom(id, meta)
+	if err != nil {
+		return dto.State{}, err
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if err := room.requireLocked(meta, dto.RoleDJ); err != nil {
+		return dto.State{}, err
+	}
+
+	if err := room.checkVersionLocked(meta); err != nil {
+		return dto.State{}, err
 	}
 
 	if room.current == nil {
-		return nil
+		return dto.State{}, ErrNoCurrentTrack
 	}
 
 	now := time.Now()
-	pos := room.basePos + now.Sub(room.startedAt).Seconds()
 
-	room.basePos = pos
+	room.basePos = 0
 	room.startedAt = now
 	room.playing = false
 
-	st := room.stateLock(now)
-	room.broadcastLocked(st)
+	room.commitLocked(now)
 
-	return nil
+	return room.stateLock(now), nil
 }
 
-func (rs *ServiceRoom) Next(id uuid.UUID) error {
-	room, err := rs.getRoom(id)
+func (rs *ServiceRoom) Next(id uuid.UUID, meta dto.Meta) (dto.State, error) {
+	room, err := rs.accessRoom(id, meta)
 	if err != nil {
-		return err
+		return dto.State{}, err
 	}
 
 	room.mu.Lock()
 	defer room.mu.Unlock()
 
-	if len(room.queue) == 0 {
-		now := time.Now()
+	if err := room.checkVersionLocked(meta); err != nil {
+		return dto.State{}, err
+	}
 
-		room.basePos = 0
-		room.current = nil
-		room.startedAt = now
-		room.playing = false
+	now := time.Now()
 
-		st := room.stateLock(now)
-		room.broadcastLocked(st)
+	// в режиме голосования next от слушателя — это только голос.
+	// Голосует подключение: по WebSocket оно известно по айди, в REST — по своему токену
+	if room.settings.VoteSkip && room.roleLocked(meta) == dto.RoleListener {
+		voter := room.contributorLocked(meta)
+		if _, ok := room.subscribers[voter]; !ok {
+			return dto.State{}, fmt.Errorf("%w: only connected listeners can vote to skip", ErrForbidden)
+		}
 
-		return nil
+		if room.current == nil {
+			return dto.State{}, ErrNoCurrentTrack
+		}
+
+		room.votes[voter] = struct{}{}
+		room.skipVotedLocked(now)
+		room.commitLocked(now)
+
+		return room.stateLock(now), nil
+	}
+
+	if err := room.requireLocked(meta, dto.RoleDJ); err != nil {
+		return dto.State{}, err
+	}
+
+	// пропускать нечего
+	if room.current == nil && len(room.queue) == 0 {
+		return dto.State{}, ErrQueueEmpty
+	}
+
+	room.nextLocked(now, false)
+	room.commitLocked(now)
+
+	return room.stateLock(now), nil
+}
+
+// Previous возвращает текущий трек в начало очереди и заново запускает последний сыгранный
+func (rs *ServiceRoom) Previous(id uuid.UUID, meta dto.Meta) (dto.State, error) {
+	room, err := rs.accessRoom(id, meta)
+	if err != nil {
+		return dto.State{}, err
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if err := room.requireLocked(meta, dto.RoleDJ); err != nil {
+		return dto.State{}, err
+	}
+
+	if err := room.checkVersionLocked(meta); err != nil {
+		return dto.State{}, err
+	}
+
+	if len(room.history) == 0 {
+		return dto.State{}, ErrHistoryEmpty
+	}
+
+	last := room.history[len(room.history)-1]
+	room.history = room.history[:len(room.history)-1]
+
+	if room.current != nil {
+		room.queue = slices.Insert(room.queue, 0, room.current)
 	}
 
 	now := time.Now()
 
+	// трек из истории играет заново как новая запись: его старый айди может еще быть в очереди
+	prev := last.QueueEntry
+	prev.ID = uuid.New()
+	room.current = &prev
+	room.playedAt = now
+	clear(room.votes)
 	room.basePos = 0
-	room.current = room.queue[0]
-	room.queue = room.queue[1:]
 	room.startedAt = now
 	room.playing = true
 
-	st := room.stateLock(now)
-	room.broadcastLocked(st)
+	room.commitLocked(now)
 
-	return nil
+	return room.stateLock(now), nil
 }
 
-func (rs *ServiceRoom) AddVideoInQueue(id uuid.UUID, video *dto.Video) error {
-	room, err := rs.getRoom(id)
+// GetHistory отдает историю сыгранных треков, самые свежие — первыми.
+// В комнату с паролем пускает так же, как GetState
+func (rs *ServiceRoom) GetHistory(id uuid.UUID, meta dto.Meta) ([]*dto.HistoryEntry, error) {
+	room, err := rs.accessRoom(id, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+
+	history := make([]*dto.HistoryEntry, len(room.history))
+	copy(history, room.history)
+	slices.Reverse(history)
+
+	return history, nil
+}
+
+func (rs *ServiceRoom) SetRepeat(id uuid.UUID, mode string, meta dto.Meta) error {
+	switch mode {
+	case dto.RepeatOff, dto.RepeatOne, dto.RepeatAll:
+	default:
+		return ErrInvalidRepeatMode
+	}
+
+	room, err := rs.accessRoom(id, meta)
 	if err != nil {
 		return err
 	}
 
 	room.mu.Lock()
 	defer room.mu.Unlock()
-	room.queue = append(room.queue, video)
 
-	now := time.Now()
+	if err := room.requireLocked(meta, dto.RoleDJ); err != nil {
+		return err
+	}
+
+	if err := room.checkVersionLocked(meta); err != nil {
+		return err
+	}
+
+	if room.repeat == mode {
+		return nil
+	}
 
-	st := room.stateLock(now)
-	room.broadcastLocked(st)
+	room.repeat = mode
+
+	room.commitLocked(time.Now())
 	return nil
 }
 
+func (rs *ServiceRoom) AddVideoInQueue(id uuid.UUID, video *dto.Video, meta dto.Meta) (*dto.QueueEntry, error) {
+	room, err := rs.accessRoom(id, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &dto.QueueEntry{ID: uuid.New(), Video: *video}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	// добавлять может любой участник, но не посторонний без токена
+	if err := room.requireMemberLocked(meta); err != nil {
+		return nil, err
+	}
+
+	if err := room.checkVersionLocked(meta); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if err := room.checkPolicyLocked(&entry.Video, now); err != nil {
+		return nil, err
+	}
+
+	entry.AddedBy = room.contributorLocked(meta)
+	if err := room.enqueueLocked(entry); err != nil {
+		return nil, err
+	}
+
+	room.commitLocked(now)
+	return entry, nil
+}
+
+// AddVideosInQueue добавляет в очередь сразу несколько треков (например, плейлист) одним обновлением.
+// Треки, которые не прошли правила комнаты или лимит, пропускаются; если не добавился ни один — отдаем первую ошибку
+func (rs *ServiceRoom) AddVideosInQueue(id uuid.UUID, videos []*dto.Video, meta dto.Meta) ([]*dto.QueueEntry, error) {
+	room, err := rs.accessRoom(id, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	// добавлять может любой участник, но не посторонний без токена
+	if err := room.requireMemberLocked(meta); err != nil {
+		return nil, err
+	}
+
+	if err := room.checkVersionLocked(meta); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	addedBy := room.contributorLocked(meta)
+
+	entries := make([]*dto.QueueEntry, 0, len(videos))
+	var firstErr error
+
+	for _, video := range videos {
+		entry := &dto.QueueEntry{ID: uuid.New(), AddedBy: addedBy, Video: *video}
+
+		err := room.checkPolicyLocked(&entry.Video, now)
+		if err == nil {
+			err = room.enqueueLocked(entry)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if len(entries) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+
+	room.commitLocked(now)
+	return entries, nil
+}
+
 func (rs *ServiceRoom) getRoom(id uuid.UUID) (*Room, error) {
 	rs.mu.RLock()
 	room, ok := rs.rooms[id]
 	rs.mu.RUnlock()
 	if !ok {
-		return nil, fmt.Errorf("room does not exist")
+		return nil, ErrRoomNotFound
 	}
 
 	return room, nil
 }
 
-func (rs *ServiceRoom) GetAllRoomsInfo() []*dto.Room {
+// GetState отдает текущий state комнаты — то же, что получают подписчики
+func (rs *ServiceRoom) GetState(id uuid.UUID, meta dto.Meta) (dto.State, error) {
+	room, err := rs.accessRoom(id, meta)
+	if err != nil {
+		return dto.State{}, err
+	}
+
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+
+	return room.stateLock(time.Now()), nil
+}
+
+// GetAllRoomsInfo отдает все комнаты, кроме приватных, в которых вызывающий не состоит
+func (rs *ServiceRoom) GetAllRoomsInfo(meta dto.Meta) []*dto.Room {
 	// 1. Делаем срез комнат под локом сервиса
 	rs.mu.RLock()
 	rooms := make([]*Room, 0, len(rs.rooms))
@@ -247,8 +548,13 @@ func (rs *ServiceRoom) GetAllRoomsInfo() []*dto.Room {
 	for _, room := range rooms {
 		room.mu.RLock()
 
+		if room.private && !room.isMemberLocked(meta) {
+			room.mu.RUnlock()
+			continue
+		}
+
 		// копия очереди, чтобы не светить внутренний слайс наружу
-		queueCopy := make([]*dto.Video, len(room.queue))
+		queueCopy := make([]*dto.QueueEntry, len(room.queue))
 		copy(queueCopy, room.queue)
 
 		res := &dto.Room{
@@ -256,7 +562,13 @@ func (rs *ServiceRoom) GetAllRoomsInfo() []*dto.Room {
 			Queue:       queueCopy,
 			Current:     room.current, // Video считаем иммутабельной
 			Playing:     room.playing,
+			Private:     room.private,
+			Protected:   room.passwordHash != nil,
+			Repeat:      room.repeat,
+			Version:     room.version,
 			Subscribers: len(room.subscribers),
+			Settings:    room.settings,
+			CreatedAt:   room.createAt,
 		}
 		room.mu.RUnlock()
 
@@ -266,8 +578,8 @@ func (rs *ServiceRoom) GetAllRoomsInfo() []*dto.Room {
 	return results
 }
 
-func (rs *ServiceRoom) DeleteVideoInQueue(id uuid.UUID, idx int) error {
-	room, err := rs.getRoom(id)
+func (rs *ServiceRoom) DeleteVideoInQueue(id uuid.UUID, entryID uuid.UUID, meta dto.Meta) error {
+	room, err := rs.accessRoom(id, meta)
 	if err != nil {
 		return err
 	}
@@ -275,21 +587,29 @@ func (rs *ServiceRoom) DeleteVideoInQueue(id uuid.UUID, idx int) error {
 	room.mu.Lock()
 	defer room.mu.Unlock()
 
-	if idx < 0 || idx >= len(room.queue) {
-		return fmt.Errorf("index out of range")
+	if err := room.requireLocked(meta, dto.RoleDJ); err != nil {
+		return err
+	}
+
+	if err := room.checkVersionLocked(meta); err != nil {
+		return err
+	}
+
+	idx, err := room.indexOfLocked(entryID)
+	if err != nil {
+		return err
 	}
 
 	room.queue = append(room.queue[:idx], room.queue[idx+1:]...)
 
-	now := time.Now()
-	st := room.stateLock(now)
-	room.broadcastLocked(st)
+	room.commitLocked(time.Now())
 
 	return nil
 }
 
-func (rs *ServiceRoom) Seek(id uuid.UUID, pos float64) error {
-	room, err := rs.getRoom(id)
+// MoveVideoInQueue переносит трек с айди entryID на позицию to в очереди
+func (rs *ServiceRoom) MoveVideoInQueue(id uuid.UUID, entryID uuid.UUID, to int, meta dto.Meta) error {
+	room, err := rs.accessRoom(id, meta)
 	if err != nil {
 		return err
 	}
@@ -297,8 +617,89 @@ func (rs *ServiceRoom) Seek(id uuid.UUID, pos float64) error {
 	room.mu.Lock()
 	defer room.mu.Unlock()
 
+	if err := room.requireLocked(meta, dto.RoleDJ); err != nil {
+		return err
+	}
+
+	if err := room.checkVersionLocked(meta); err != nil {
+		return err
+	}
+
+	from, err := room.indexOfLocked(entryID)
+	if err != nil {
+		return err
+	}
+
+	if to < 0 || to >= len(room.queue) {
+		return ErrIndexOutOfRange
+	}
+
+	if from == to {
+		return nil
+	}
+
+	entry := room.queue[from]
+	room.queue = append(room.queue[:from], room.queue[from+1:]...)
+	room.queue = slices.Insert(room.queue, to, entry)
+
+	room.commitLocked(time.Now())
+
+	return nil
+}
+
+// PlayNext ставит трек в начало очереди, чтобы он заиграл следующим
+func (rs *ServiceRoom) PlayNext(id uuid.UUID, video *dto.Video, meta dto.Meta) (*dto.QueueEntry, error) {
+	room, err := rs.accessRoom(id, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &dto.QueueEntry{ID: uuid.New(), Video: *video}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if err := room.requireLocked(meta, dto.RoleDJ); err != nil {
+		return nil, err
+	}
+
+	if err := room.checkVersionLocked(meta); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if err := room.checkPolicyLocked(&entry.Video, now); err != nil {
+		return nil, err
+	}
+
+	entry.AddedBy = room.contributorLocked(meta)
+	room.queue = slices.Insert(room.queue, 0, entry)
+
+	room.commitLocked(now)
+
+	return entry, nil
+}
+
+func (rs *ServiceRoom) Seek(id uuid.UUID, pos float64, meta dto.Meta) error {
+	room, err := rs.accessRoom(id, meta)
+	if err != nil {
+		return err
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if err := room.requireLocked(meta, dto.RoleDJ); err != nil {
+		return err
+	}
+
+	if err := room.checkVersionLocked(meta); err != nil {
+		return err
+	}
+
 	if room.current == nil {
-		return fmt.Errorf("no current track")
+		return ErrNoCurrentTrack
 	}
 
 	// Нормализуем позицию
@@ -319,9 +720,8 @@ func (rs *ServiceRoom) Seek(id uuid.UUID, pos float64) error {
 		room.startedAt = now
 	}
 
-	// собираем новый state обычным способом
-	st := room.stateLock(now)
-	room.broadcastLocked(st)
+	// собираем новый state обычным способом и переставляем таймер конца трека
+	room.commitLocked(now)
 
 	return nil
 }
@@ -0,0 +1,225 @@
+package room
+
+import (
+	"github.com/google/uuid"
+	"mrs/internal/dto"
+	"testing"
+	"time"
+)
+
+// nearEnd — куда перематываем десятисекундный трек, чтобы таймер конца сработал через 50 мс
+const nearEnd = 9.95
+
+// newPlayingRoom создает комнату с треками по 10 секунд и запускает первый
+func newPlayingRoom(t *testing.T, repeat string, tracks ...string) (*ServiceRoom, *Room, dto.Meta) {
+	t.Helper()
+
+	rs := &ServiceRoom{rooms: make(map[uuid.UUID]*Room)}
+	id, owner, err := rs.CreateRoom(dto.CreateRoomRequest{})
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	meta := dto.Meta{Token: owner}
+
+	for _, title := range tracks {
+		video := &dto.Video{URL: title, Title: title, Duration: 10}
+		if _, err := rs.AddVideoInQueue(id, video, meta); err != nil {
+			t.Fatalf("AddVideoInQueue: %v", err)
+		}
+	}
+
+	if err := rs.SetRepeat(id, repeat, meta); err != nil {
+		t.Fatalf("SetRepeat: %v", err)
+	}
+	if _, err := rs.Play(id, meta); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+
+	room, _ := rs.getRoom(id)
+	t.Cleanup(func() { rs.RemoveRoom(id) })
+
+	return rs, room, meta
+}
+
+type snapshot struct {
+	current string
+	playing bool
+	history int
+	queue   int
+}
+
+func snapshotOf(room *Room) snapshot {
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+
+	s := snapshot{playing: room.playing, history: len(room.history), queue: len(room.queue)}
+	if room.current != nil {
+		s.current = room.current.Title
+	}
+
+	return s
+}
+
+// waitSnapshot ждет, пока комната придет в состояние want, или отдает последнее увиденное
+func waitSnapshot(room *Room, want snapshot, timeout time.Duration) snapshot {
+	deadline := time.Now().Add(timeout)
+	for {
+		got := snapshotOf(room)
+		if got == want || time.Now().After(deadline) {
+			return got
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTrackEndTimer(t *testing.T) {
+	tests := []struct {
+		name   string
+		repeat string
+		tracks []string
+		act    func(rs *ServiceRoom, room *Room, meta dto.Meta) error
+		want   snapshot
+	}{
+		{
+			name:   "advances to next track",
+			repeat: dto.RepeatOff,
+			tracks: []string{"a", "b"},
+			act: func(rs *ServiceRoom, room *Room, meta dto.Meta) error {
+				return rs.Seek(room.id, nearEnd, meta)
+			},
+			want: snapshot{current: "b", playing: true, history: 1},
+		},
+		{
+			name:   "stops after last track",
+			repeat: dto.RepeatOff,
+			tracks: []string{"a"},
+			act: func(rs *ServiceRoom, room *Room, meta dto.Meta) error {
+				return rs.Seek(room.id, nearEnd, meta)
+			},
+			want: snapshot{history: 1},
+		},
+		{
+			name:   "repeat one replays and records the play",
+			repeat: dto.RepeatOne,
+			tracks: []string{"a", "b"},
+			act: func(rs *ServiceRoom, room *Room, meta dto.Meta) error {
+				return rs.Seek(room.id, nearEnd, meta)
+			},
+			want: snapshot{current: "a", playing: true, history: 1, queue: 1},
+		},
+		{
+			name:   "repeat all requeues finished track",
+			repeat: dto.RepeatAll,
+			tracks: []string{"a", "b"},
+			act: func(rs *ServiceRoom, room *Room, meta dto.Meta) error {
+				return rs.Seek(room.id, nearEnd, meta)
+			},
+			want: snapshot{current: "b", playing: true, history: 1, queue: 1},
+		},
+		{
+			name:   "pause cancels timer",
+			repeat: dto.RepeatOff,
+			tracks: []string{"a", "b"},
+			act: func(rs *ServiceRoom, room *Room, meta dto.Meta) error {
+				if err := rs.Seek(room.id, nearEnd, meta); err != nil {
+					return err
+				}
+				_, err := rs.Pause(room.id, meta)
+				return err
+			},
+			want: snapshot{current: "a", queue: 1},
+		},
+		{
+			name:   "seek back reschedules timer",
+			repeat: dto.RepeatOff,
+			tracks: []string{"a", "b"},
+			act: func(rs *ServiceRoom, room *Room, meta dto.Meta) error {
+				if err := rs.Seek(room.id, nearEnd, meta); err != nil {
+					return err
+				}
+				return rs.Seek(room.id, 0, meta)
+			},
+			want: snapshot{current: "a", playing: true, queue: 1},
+		},
+		{
+			name:   "resume after pause schedules timer again",
+			repeat: dto.RepeatOff,
+			tracks: []string{"a", "b"},
+			act: func(rs *ServiceRoom, room *Room, meta dto.Meta) error {
+				if err := rs.Seek(room.id, nearEnd, meta); err != nil {
+					return err
+				}
+				if _, err := rs.Pause(room.id, meta); err != nil {
+					return err
+				}
+				_, err := rs.Play(room.id, meta)
+				return err
+			},
+			want: snapshot{current: "b", playing: true, history: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, room, meta := newPlayingRoom(t, tt.repeat, tt.tracks...)
+
+			if err := tt.act(rs, room, meta); err != nil {
+				t.Fatalf("act: %v", err)
+			}
+
+			// ждем с запасом: если таймер должен сработать — дождемся, если не должен — успел бы
+			if got := waitSnapshot(room, tt.want, 300*time.Millisecond); got != tt.want {
+				t.Errorf("room = %+v, want %+v", got, tt.want)
+			}
+
+			// состояние устоялось и больше не меняется старыми таймерами
+			time.Sleep(100 * time.Millisecond)
+			if got := snapshotOf(room); got != tt.want {
+				t.Errorf("room later = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepeatOneUpdatesStartTime(t *testing.T) {
+	rs, room, meta := newPlayingRoom(t, dto.RepeatOne, "a")
+
+	room.mu.RLock()
+	firstStart := room.playedAt
+	room.mu.RUnlock()
+
+	if err := rs.Seek(room.id, nearEnd, meta); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+
+	want := snapshot{current: "a", playing: true, history: 1}
+	if got := waitSnapshot(room, want, 300*time.Millisecond); got != want {
+		t.Fatalf("room = %+v, want %+v", got, want)
+	}
+
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+
+	if !room.playedAt.After(firstStart) {
+		t.Errorf("playedAt = %v, want after %v", room.playedAt, firstStart)
+	}
+	if got := room.history[0].StartedAt; !got.Equal(firstStart) {
+		t.Errorf("history started_at = %v, want %v", got, firstStart)
+	}
+}
+
+func TestTrackEndStaleGeneration(t *testing.T) {
+	_, room, _ := newPlayingRoom(t, dto.RepeatOff, "a", "b")
+
+	room.mu.RLock()
+	stale := room.timerGen - 1
+	room.mu.RUnlock()
+
+	// срабатывание таймера, который уже заменили, ничего не меняет
+	room.onTrackEnd(stale)
+
+	want := snapshot{current: "a", playing: true, queue: 1}
+	if got := snapshotOf(room); got != want {
+		t.Errorf("room = %+v, want %+v", got, want)
+	}
+}
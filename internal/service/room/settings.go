@@ -0,0 +1,119 @@
+package room
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"mrs/internal/dto"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultVoteShare = 0.5 // по умолчанию для пропуска трека нужна половина подписчиков
+
+	maxNameLength        = 100
+	maxDescriptionLength = 1000
+)
+
+func defaultSettings() dto.RoomSettings {
+	return dto.RoomSettings{VoteShare: defaultVoteShare}
+}
+
+// applySettings накладывает изменения на настройки, проверяя значения.
+// При ошибке settings не меняются
+func applySettings(settings *dto.RoomSettings, patch dto.RoomSettingsPatch) error {
+	next := *settings
+
+	if patch.Name != nil {
+		if utf8.RuneCountInString(*patch.Name) > maxNameLength {
+			return fmt.Errorf("%w: name is longer than %d characters", ErrInvalidSettings, maxNameLength)
+		}
+		next.Name = *patch.Name
+	}
+
+	if patch.Description != nil {
+		if utf8.RuneCountInString(*patch.Description) > maxDescriptionLength {
+			return fmt.Errorf("%w: description is longer than %d characters", ErrInvalidSettings, maxDescriptionLength)
+		}
+		next.Description = *patch.Description
+	}
+
+	if patch.VoteSkip != nil {
+		next.VoteSkip = *patch.VoteSkip
+	}
+
+	if patch.VoteShare != nil {
+		if *patch.VoteShare <= 0 || *patch.VoteShare > 1 {
+			return fmt.Errorf("%w: vote share must be in (0, 1]", ErrInvalidSettings)
+		}
+		next.VoteShare = *patch.VoteShare
+	}
+
+	if patch.FairQueue != nil {
+		next.FairQueue = *patch.FairQueue
+	}
+
+	if patch.UserLimit != nil {
+		if *patch.UserLimit < 0 {
+			return fmt.Errorf("%w: user limit must be non-negative", ErrInvalidSettings)
+		}
+		next.UserLimit = *patch.UserLimit
+	}
+
+	if patch.Policy != nil {
+		p := *patch.Policy
+		if p.MaxTrackDuration < 0 || p.MaxQueueDuration < 0 || p.ReplayCooldown < 0 {
+			return fmt.Errorf("%w: policy limits must be non-negative", ErrInvalidSettings)
+		}
+		next.Policy = p
+	}
+
+	*settings = next
+	return nil
+}
+
+// UpdateSettings меняет настройки комнаты (только владелец) и рассылает новый state
+func (rs *ServiceRoom) UpdateSettings(id uuid.UUID, patch dto.RoomSettingsPatch, meta dto.Meta) (dto.RoomSettings, error) {
+	room, err := rs.accessRoom(id, meta)
+	if err != nil {
+		return dto.RoomSettings{}, err
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if err := room.requireLocked(meta, dto.RoleOwner); err != nil {
+		return dto.RoomSettings{}, err
+	}
+
+	if err := room.checkVersionLocked(meta); err != nil {
+		return dto.RoomSettings{}, err
+	}
+
+	prev := room.settings
+	if err := applySettings(&room.settings, patch); err != nil {
+		return dto.RoomSettings{}, err
+	}
+
+	// при включении честной очереди раскладываем уже набранную очередь по кругу
+	if room.settings.FairQueue && !prev.FairQueue {
+		queue := make([]*dto.QueueEntry, 0, cap(room.queue))
+		for _, entry := range room.queue {
+			queue = fairInsert(queue, entry)
+		}
+		room.queue = queue
+	}
+
+	// голоса, отданные в другом режиме, не считаем
+	if room.settings.VoteSkip != prev.VoteSkip {
+		clear(room.votes)
+	}
+
+	now := time.Now()
+
+	// с меньшей долей голосов может уже хватать на пропуск
+	room.skipVotedLocked(now)
+	room.commitLocked(now)
+
+	return room.settings, nil
+}
@@ -3,31 +3,142 @@ package http_transport
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"hash/fnv"
+	"io"
 	"mrs/internal/dto"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
-type ServiceYoutube interface {
-	GetListVideo(ctx context.Context, query string) ([]*dto.Video, error)
+type ServiceAudio interface {
+	GetListVideo(ctx context.Context, query dto.SearchQuery) (*dto.SearchPage, error)
+	ResolveVideo(ctx context.Context, video *dto.Video) (*dto.Video, error)
+	GetPlaylist(ctx context.Context, req dto.PlaylistRequest) (*dto.Playlist, error)
+	CacheStats() map[string]dto.CacheReport
+	QuotaReport() map[string]dto.QuotaReport
 }
 
 type ServiceRoom interface {
-	CreateRoom() (uuid.UUID, error)
-	AddVideoInQueue(id uuid.UUID, video *dto.Video) error
-	GetAllRoomsInfo() []*dto.Room
-	DeleteVideoInQueue(id uuid.UUID, idx int) error
-	Seek(id uuid.UUID, pos float64) error
+	CreateRoom(req dto.CreateRoomRequest) (uuid.UUID, string, error)
+	AddVideoInQueue(id uuid.UUID, video *dto.Video, meta dto.Meta) (*dto.QueueEntry, error)
+	AddVideosInQueue(id uuid.UUID, videos []*dto.Video, meta dto.Meta) ([]*dto.QueueEntry, error)
+	GetAllRoomsInfo(meta dto.Meta) []*dto.Room
+	ListRooms(query dto.RoomQuery, meta dto.Meta) (*dto.RoomPage, error)
+	GetState(id uuid.UUID, meta dto.Meta) (dto.State, error)
+	DeleteVideoInQueue(id uuid.UUID, entryID uuid.UUID, meta dto.Meta) error
+	Seek(id uuid.UUID, pos float64, meta dto.Meta) error
+	SetRepeat(id uuid.UUID, mode string, meta dto.Meta) error
+	SetRole(id uuid.UUID, userID int, role string, meta dto.Meta) error
+	UpdateSettings(id uuid.UUID, patch dto.RoomSettingsPatch, meta dto.Meta) (dto.RoomSettings, error)
+	MoveVideoInQueue(id uuid.UUID, entryID uuid.UUID, to int, meta dto.Meta) error
+	PlayNext(id uuid.UUID, video *dto.Video, meta dto.Meta) (*dto.QueueEntry, error)
+	GetHistory(id uuid.UUID, meta dto.Meta) ([]*dto.HistoryEntry, error)
+	Authorize(id uuid.UUID, meta dto.Meta, role string) error
+	Play(id uuid.UUID, meta dto.Meta) (dto.State, error)
+	Pause(id uuid.UUID, meta dto.Meta) (dto.State, error)
+	Stop(id uuid.UUID, meta dto.Meta) (dto.State, error)
+	Next(id uuid.UUID, meta dto.Meta) (dto.State, error)
+	Previous(id uuid.UUID, meta dto.Meta) (dto.State, error)
 }
 
 type Handler struct {
-	servYoutube ServiceYoutube
-	servRoom    ServiceRoom
+	servAudio ServiceAudio
+	servRoom  ServiceRoom
 }
 
-func NewHandler(service ServiceYoutube, servRoom ServiceRoom) *Handler {
-	return &Handler{servYoutube: service, servRoom: servRoom}
+func NewHandler(service ServiceAudio, servRoom ServiceRoom) *Handler {
+	return &Handler{servAudio: service, servRoom: servRoom}
+}
+
+// metaFromRequest достает из запроса ожидаемую версию комнаты (query version или заголовок If-Match),
+// токен вызывающего (заголовок X-Room-Token или query token) и пароль комнаты (заголовок X-Room-Password).
+// Устаревший If-Match — 412, устаревший version — 409; If-Match: * версию не проверяет
+func metaFromRequest(r *http.Request) (dto.Meta, error) {
+	meta := dto.Meta{Token: TokenFromRequest(r), Password: r.Header.Get("X-Room-Password")}
+
+	raw := r.URL.Query().Get("version")
+	if raw == "" {
+		raw = strings.TrimSpace(r.Header.Get("If-Match"))
+		if raw == "*" {
+			return meta, nil
+		}
+
+		raw = strings.Trim(strings.TrimPrefix(raw, "W/"), `"`)
+		// ETag — "{version}-{отпечаток}", для проверки нужна только версия
+		raw, _, _ = strings.Cut(raw, "-")
+		meta.IfMatch = raw != ""
+	}
+
+	if raw == "" {
+		return meta, nil
+	}
+
+	version, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return meta, fmt.Errorf("version must be a non-negative integer")
+	}
+
+	meta.Version = version
+	return meta, nil
+}
+
+// TokenFromRequest достает секрет владельца или токен подключения.
+// Query token нужен для WebSocket, где браузер не дает выставить заголовки
+func TokenFromRequest(r *http.Request) string {
+	if token := r.Header.Get("X-Room-Token"); token != "" {
+		return token
+	}
+
+	return r.URL.Query().Get("token")
+}
+
+// pathOrQuery берет параметр из шаблона пути (маршруты v2), а если его там нет — из query (маршруты v1)
+func pathOrQuery(r *http.Request, name, query string) string {
+	if value := r.PathValue(name); value != "" {
+		return value
+	}
+
+	return r.URL.Query().Get(query)
+}
+
+// roomIDFromRequest достает айди комнаты: /rooms/{id}/... или ?id=
+func roomIDFromRequest(r *http.Request) (uuid.UUID, error) {
+	if r.PathValue("id") != "" {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("room id is invalid")
+		}
+		return id, nil
+	}
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("query parameter id is required")
+	}
+
+	return id, nil
+}
+
+// entryIDFromRequest достает айди записи в очереди: /rooms/{id}/queue/{entryId} или ?entry=
+func entryIDFromRequest(r *http.Request) (uuid.UUID, error) {
+	if r.PathValue("entryId") != "" {
+		entryID, err := uuid.Parse(r.PathValue("entryId"))
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("entry id is invalid")
+		}
+		return entryID, nil
+	}
+
+	entryID, err := uuid.Parse(r.URL.Query().Get("entry"))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("query parameter entry is required")
+	}
+
+	return entryID, nil
 }
 
 func (h *Handler) GetListVideo(w http.ResponseWriter, r *http.Request) {
@@ -37,9 +148,24 @@ func (h *Handler) GetListVideo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := h.servYoutube.GetListVideo(r.Context(), query)
+	search := dto.SearchQuery{
+		Provider:  r.URL.Query().Get("provider"),
+		Query:     query,
+		PageToken: r.URL.Query().Get("page_token"),
+	}
+
+	if sizeStr := r.URL.Query().Get("page_size"); sizeStr != "" {
+		size, err := strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil || size <= 0 {
+			WriteJsonError(w, http.StatusBadRequest, "query parameter page_size must be a positive integer")
+			return
+		}
+		search.PageSize = size
+	}
+
+	res, err := h.servAudio.GetListVideo(r.Context(), search)
 	if err != nil {
-		WriteJsonError(w, http.StatusInternalServerError, err.Error())
+		WriteError(w, err)
 		return
 	}
 
@@ -52,16 +178,23 @@ func (h *Handler) GetListVideo(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) CreateRoom(w http.ResponseWriter, r *http.Request) {
-	id, err := h.servRoom.CreateRoom()
+	// тело необязательное: без него создается открытая комната без пароля
+	var req dto.CreateRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		WriteJsonError(w, http.StatusBadRequest, "body is invalid")
+		return
+	}
+
+	id, ownerToken, err := h.servRoom.CreateRoom(req)
 	if err != nil {
-		WriteJsonError(w, http.StatusInternalServerError, err.Error())
+		WriteError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(http.StatusCreated)
 
-	resp := dto.ResponseRoom{ID: id}
+	resp := dto.ResponseRoom{ID: id, OwnerToken: ownerToken}
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		WriteJsonError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -69,15 +202,15 @@ func (h *Handler) CreateRoom(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) AddVideoInQueue(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		WriteJsonError(w, http.StatusBadRequest, "query parameter id is required")
+	id, err := roomIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	id, err := uuid.Parse(idStr)
+	meta, err := metaFromRequest(r)
 	if err != nil {
-		WriteJsonError(w, http.StatusBadRequest, "query parameter id is required")
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -88,18 +221,100 @@ func (h *Handler) AddVideoInQueue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.servRoom.AddVideoInQueue(id, &video)
+	// права проверяем до провайдера, чтобы отклоненные запросы не тратили квоту
+	if err := h.servRoom.Authorize(id, meta, dto.RoleListener); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	resolved, err := h.servAudio.ResolveVideo(r.Context(), &video)
 	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	entry, err := h.servRoom.AddVideoInQueue(id, resolved, meta)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
 		WriteJsonError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// GetCacheStats отдает счетчики попаданий и промахов кешей по провайдерам
+func (h *Handler) GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(h.servAudio.CacheStats()); err != nil {
+		WriteJsonError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// GetQuota отдает расход квоты по провайдерам и сколько ее осталось
+func (h *Handler) GetQuota(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(h.servAudio.QuotaReport()); err != nil {
+		WriteJsonError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// AddPlaylistInQueue ставит в очередь треки плейлиста одним обновлением комнаты
+func (h *Handler) AddPlaylistInQueue(w http.ResponseWriter, r *http.Request) {
+	id, err := roomIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	meta, err := metaFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req dto.PlaylistRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJsonError(w, http.StatusBadRequest, "body is required")
+		return
+	}
+
+	// права проверяем до YouTube: импорт плейлиста — несколько запросов к квоте
+	if err := h.servRoom.Authorize(id, meta, dto.RoleListener); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	playlist, err := h.servAudio.GetPlaylist(r.Context(), req)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	entries, err := h.servRoom.AddVideosInQueue(id, playlist.Videos, meta)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
 
+	resp := dto.PlaylistImport{
+		Entries:   entries,
+		Skipped:   playlist.Skipped,
+		Rejected:  len(playlist.Videos) - len(entries),
+		Truncated: playlist.Truncated,
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		WriteJsonError(w, http.StatusInternalServerError, err.Error())
+	}
 }
 
 func (h *Handler) GetAllRoomsInfo(w http.ResponseWriter, r *http.Request) {
-	rooms := h.servRoom.GetAllRoomsInfo()
+	rooms := h.servRoom.GetAllRoomsInfo(dto.Meta{Token: TokenFromRequest(r)})
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	if err := json.NewEncoder(w).Encode(rooms); err != nil {
 		WriteJsonError(w, http.StatusInternalServerError, err.Error())
@@ -107,62 +322,509 @@ func (h *Handler) GetAllRoomsInfo(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) DeleteVideoInQueue(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		WriteJsonError(w, http.StatusBadRequest, "query parameter id is required")
+	id, err := roomIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	id, err := uuid.Parse(idStr)
+	meta, err := metaFromRequest(r)
 	if err != nil {
-		WriteJsonError(w, http.StatusBadRequest, "query parameter id is required")
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	idxStr := r.URL.Query().Get("idx")
-	if idxStr == "" {
-		WriteJsonError(w, http.StatusBadRequest, "query parameter idx is required")
-	}
-
-	idx, err := strconv.Atoi(idxStr)
-	if err != nil || idx < 0 {
-		WriteJsonError(w, http.StatusBadRequest, "query parameter idx is required")
+	entryID, err := entryIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	err = h.servRoom.DeleteVideoInQueue(id, idx)
+	err = h.servRoom.DeleteVideoInQueue(id, entryID, meta)
 	if err != nil {
-		WriteJsonError(w, http.StatusInternalServerError, err.Error())
+		WriteError(w, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
 func (h *Handler) Seek(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		WriteJsonError(w, http.StatusBadRequest, "query parameter id is required")
+	id, err := roomIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	id, err := uuid.Parse(idStr)
+	meta, err := metaFromRequest(r)
 	if err != nil {
-		WriteJsonError(w, http.StatusBadRequest, "query parameter id is required")
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	posStr := r.URL.Query().Get("pos")
 	if posStr == "" {
 		WriteJsonError(w, http.StatusBadRequest, "query parameter pos is required")
+		return
 	}
 
 	pos, err := strconv.ParseFloat(posStr, 64)
 	if err != nil {
 		WriteJsonError(w, http.StatusBadRequest, "query parameter pos is required")
+		return
+	}
+
+	if err := h.servRoom.Seek(id, pos, meta); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) SetRepeat(w http.ResponseWriter, r *http.Request) {
+	id, err := roomIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	meta, err := metaFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case dto.RepeatOff, dto.RepeatOne, dto.RepeatAll:
+	default:
+		WriteJsonError(w, http.StatusBadRequest, "query parameter mode must be one of: off, one, all")
+		return
+	}
+
+	if err := h.servRoom.SetRepeat(id, mode, meta); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) MoveVideoInQueue(w http.ResponseWriter, r *http.Request) {
+	id, err := roomIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	meta, err := metaFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entryID, err := entryIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	if err := h.servRoom.Seek(id, pos); err != nil {
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil || to < 0 {
+		WriteJsonError(w, http.StatusBadRequest, "query parameter to is required")
+		return
+	}
+
+	if err := h.servRoom.MoveVideoInQueue(id, entryID, to, meta); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) PlayNext(w http.ResponseWriter, r *http.Request) {
+	id, err := roomIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	meta, err := metaFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var video dto.Video
+
+	if err := json.NewDecoder(r.Body).Decode(&video); err != nil {
+		WriteJsonError(w, http.StatusBadRequest, "body is required")
+		return
+	}
+
+	// права проверяем до провайдера, чтобы отклоненные запросы не тратили квоту
+	if err := h.servRoom.Authorize(id, meta, dto.RoleDJ); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	resolved, err := h.servAudio.ResolveVideo(r.Context(), &video)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	entry, err := h.servRoom.PlayNext(id, resolved, meta)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
 		WriteJsonError(w, http.StatusInternalServerError, err.Error())
 	}
+}
+
+func (h *Handler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := roomIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	meta, err := metaFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	history, err := h.servRoom.GetHistory(id, meta)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(history); err != nil {
+		WriteJsonError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h *Handler) SetVoteSkip(w http.ResponseWriter, r *http.Request) {
+	id, err := roomIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	meta, err := metaFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, "query parameter enabled is required")
+		return
+	}
+
+	patch := dto.RoomSettingsPatch{VoteSkip: &enabled}
+
+	if shareStr := r.URL.Query().Get("share"); shareStr != "" {
+		share, err := strconv.ParseFloat(shareStr, 64)
+		if err != nil || share <= 0 || share > 1 {
+			WriteJsonError(w, http.StatusBadRequest, "query parameter share must be in (0, 1]")
+			return
+		}
+		patch.VoteShare = &share
+	}
+
+	if _, err := h.servRoom.UpdateSettings(id, patch, meta); err != nil {
+		WriteError(w, err)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
+
+func (h *Handler) SetRole(w http.ResponseWriter, r *http.Request) {
+	id, err := roomIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	meta, err := metaFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(pathOrQuery(r, "userId", "user"))
+	if err != nil || userID <= 0 {
+		WriteJsonError(w, http.StatusBadRequest, "query parameter user is required")
+		return
+	}
+
+	role := r.URL.Query().Get("role")
+	if role != dto.RoleDJ && role != dto.RoleListener {
+		WriteJsonError(w, http.StatusBadRequest, "query parameter role must be one of: dj, listener")
+		return
+	}
+
+	if err := h.servRoom.SetRole(id, userID, role, meta); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) SetFairQueue(w http.ResponseWriter, r *http.Request) {
+	id, err := roomIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	meta, err := metaFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, "query parameter enabled is required")
+		return
+	}
+
+	patch := dto.RoomSettingsPatch{FairQueue: &enabled}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 0 {
+			WriteJsonError(w, http.StatusBadRequest, "query parameter limit must be a non-negative integer")
+			return
+		}
+		patch.UserLimit = &limit
+	}
+
+	if _, err := h.servRoom.UpdateSettings(id, patch, meta); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) SetPolicy(w http.ResponseWriter, r *http.Request) {
+	id, err := roomIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	meta, err := metaFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var policy dto.Policy
+
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		WriteJsonError(w, http.StatusBadRequest, "body is required")
+		return
+	}
+
+	if _, err := h.servRoom.UpdateSettings(id, dto.RoomSettingsPatch{Policy: &policy}, meta); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	id, err := roomIDFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	meta, err := metaFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var patch dto.RoomSettingsPatch
+
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		WriteJsonError(w, http.StatusBadRequest, "body is required")
+		return
+	}
+
+	settings, err := h.servRoom.UpdateSettings(id, patch, meta)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(settings); err != nil {
+		WriteJsonError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h *Handler) ListRooms(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	query := dto.RoomQuery{
+		Cursor:     q.Get("cursor"),
+		Sort:       q.Get("sort"),
+		Visibility: q.Get("visibility"),
+		Name:       q.Get("name"),
+	}
+
+	if limitStr := q.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			WriteJsonError(w, http.StatusBadRequest, "query parameter limit must be a positive integer")
+			return
+		}
+		query.Limit = limit
+	}
+
+	if playingStr := q.Get("playing"); playingStr != "" {
+		playing, err := strconv.ParseBool(playingStr)
+		if err != nil {
+			WriteJsonError(w, http.StatusBadRequest, "query parameter playing must be true or false")
+			return
+		}
+		query.Playing = &playing
+	}
+
+	page, err := h.servRoom.ListRooms(query, dto.Meta{Token: TokenFromRequest(r)})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		WriteJsonError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// GetState отдает state одной комнаты. ETag — версия комнаты и отпечаток участников и голосов,
+// поэтому с If-None-Match поллинг получает 304, пока в комнате ничего не поменялось, а сам ETag подходит для If-Match
+func (h *Handler) GetState(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		WriteJsonError(w, http.StatusNotFound, "room does not exist")
+		return
+	}
+
+	meta, err := metaFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	state, err := h.servRoom.GetState(id, meta)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	etag := stateETag(state)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		WriteJsonError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// stateETag — версия комнаты и отпечаток того, что меняется без новой версии:
+// вход и выход участников меняют список members и счет голосов
+func stateETag(state dto.State) string {
+	h := fnv.New64a()
+	for _, m := range state.Members {
+		fmt.Fprintf(h, "%d:%s;", m.UserID, m.Role)
+	}
+	fmt.Fprintf(h, "%d/%d", state.Votes, state.VotesNeeded)
+
+	return fmt.Sprintf(`"%d-%x"`, state.Version, h.Sum64())
+}
+
+// etagMatches проверяет If-None-Match: список тегов через запятую или *
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *Handler) Play(w http.ResponseWriter, r *http.Request) {
+	h.playback(w, r, h.servRoom.Play)
+}
+
+func (h *Handler) Pause(w http.ResponseWriter, r *http.Request) {
+	h.playback(w, r, h.servRoom.Pause)
+}
+
+func (h *Handler) Stop(w http.ResponseWriter, r *http.Request) {
+	h.playback(w, r, h.servRoom.Stop)
+}
+
+func (h *Handler) Next(w http.ResponseWriter, r *http.Request) {
+	h.playback(w, r, h.servRoom.Next)
+}
+
+func (h *Handler) Previous(w http.ResponseWriter, r *http.Request) {
+	h.playback(w, r, h.servRoom.Previous)
+}
+
+// playback выполняет команду плеера для комнаты из пути и отдает новый state с ETag
+func (h *Handler) playback(w http.ResponseWriter, r *http.Request, action func(uuid.UUID, dto.Meta) (dto.State, error)) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		WriteJsonError(w, http.StatusNotFound, "room does not exist")
+		return
+	}
+
+	meta, err := metaFromRequest(r)
+	if err != nil {
+		WriteJsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	state, err := action(id, meta)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", stateETag(state))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		WriteJsonError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -2,13 +2,134 @@ package http_transport
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
+	"math"
 	"mrs/internal/dto"
+	"mrs/internal/service/audio"
+	"mrs/internal/service/room"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func WriteJsonError(w http.ResponseWriter, code int, message string) {
+// ErrBadRequest — ошибка разбора запроса или команды на стороне транспорта
+var ErrBadRequest = errors.New("bad request")
+
+// errorKind связывает ошибку сервиса с HTTP-статусом и кодом в ответе
+type errorKind struct {
+	err    error
+	status int
+	code   string
+}
+
+var errorKinds = []errorKind{
+	{room.ErrRoomNotFound, http.StatusNotFound, "room_not_found"},
+	{room.ErrEntryNotFound, http.StatusNotFound, "entry_not_found"},
+	{room.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+
+	{room.ErrQueueEmpty, http.StatusConflict, "queue_empty"},
+	{room.ErrNoCurrentTrack, http.StatusConflict, "no_current_track"},
+	{room.ErrHistoryEmpty, http.StatusConflict, "history_empty"},
+	{room.ErrPreconditionFailed, http.StatusPreconditionFailed, "precondition_failed"},
+	{room.ErrVersionConflict, http.StatusConflict, "version_conflict"},
+	{room.ErrQueueLimit, http.StatusConflict, "queue_limit"},
+	{room.ErrDuplicateTrack, http.StatusConflict, "duplicate_track"},
+	{room.ErrReplayCooldown, http.StatusConflict, "replay_cooldown"},
+
+	{room.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{room.ErrWrongPassword, http.StatusUnauthorized, "wrong_password"},
+
+	{room.ErrInvalidSettings, http.StatusBadRequest, "invalid_settings"},
+	{room.ErrInvalidQuery, http.StatusBadRequest, "invalid_query"},
+	{room.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor"},
+	{room.ErrInvalidRepeatMode, http.StatusBadRequest, "invalid_repeat_mode"},
+	{room.ErrInvalidRole, http.StatusBadRequest, "invalid_role"},
+	{ErrBadRequest, http.StatusBadRequest, "bad_request"},
+
+	{room.ErrIndexOutOfRange, http.StatusUnprocessableEntity, "index_out_of_range"},
+	{room.ErrTrackTooLong, http.StatusUnprocessableEntity, "track_too_long"},
+	{room.ErrUnknownDuration, http.StatusUnprocessableEntity, "unknown_duration"},
+	{room.ErrQueueTooLong, http.StatusUnprocessableEntity, "queue_too_long"},
+
+	{audio.ErrUnknownProvider, http.StatusBadRequest, "unknown_provider"},
+	{audio.ErrEmptyQuery, http.StatusBadRequest, "empty_query"},
+	{audio.ErrInvalidPage, http.StatusBadRequest, "invalid_page"},
+	{audio.ErrInvalidVideo, http.StatusBadRequest, "invalid_video"},
+	{audio.ErrVideoNotFound, http.StatusNotFound, "video_not_found"},
+	{audio.ErrInvalidPlaylist, http.StatusBadRequest, "invalid_playlist"},
+	{audio.ErrPlaylistNotFound, http.StatusNotFound, "playlist_not_found"},
+	{audio.ErrUpstream, http.StatusBadGateway, "upstream_error"},
+	{audio.ErrQuotaExhausted, http.StatusServiceUnavailable, "quota_exhausted"},
+}
+
+// WriteJsonError пишет ошибку самого транспорта, код в ответе берется из статуса (bad_request, not_found, ...)
+func WriteJsonError(w http.ResponseWriter, status int, message string) {
+	writeErrorResponse(w, status, dto.ErrorResponse{Code: codeFromStatus(status), Message: message})
+}
+
+// retryAfter — ошибка, после которой запрос есть смысл повторить через время (например, кончилась квота)
+type retryAfter interface {
+	RetryAfter() time.Duration
+}
+
+// WriteError пишет ошибку сервиса со статусом и кодом из errorKinds
+func WriteError(w http.ResponseWriter, err error) {
+	var ra retryAfter
+	if errors.As(err, &ra) {
+		seconds := int(math.Ceil(ra.RetryAfter().Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	}
+
+	writeErrorResponse(w, StatusFromError(err), ErrorResponseFrom(err))
+}
+
+func writeErrorResponse(w http.ResponseWriter, status int, resp dto.ErrorResponse) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(code)
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// ErrorResponseFrom собирает тело ошибки, его же получают WebSocket-клиенты
+func ErrorResponseFrom(err error) dto.ErrorResponse {
+	// неизвестная ошибка (bcrypt, crypto/rand, ...) остается в логе сервера, клиенту — только статус
+	kind, ok := kindOf(err)
+	if !ok {
+		log.Printf("internal error: %v", err)
+		status := http.StatusInternalServerError
+		return dto.ErrorResponse{Code: codeFromStatus(status), Message: strings.ToLower(http.StatusText(status))}
+	}
+
+	// подробности ошибки YouTube остаются в логе сервера: в них бывает адрес запроса с ключом
+	if kind.err == audio.ErrUpstream {
+		return dto.ErrorResponse{Code: kind.code, Message: kind.err.Error()}
+	}
+
+	return dto.ErrorResponse{Code: kind.code, Message: err.Error()}
+}
+
+// StatusFromError подбирает HTTP-статус под ошибку сервиса
+func StatusFromError(err error) int {
+	if kind, ok := kindOf(err); ok {
+		return kind.status
+	}
+
+	return http.StatusInternalServerError
+}
+
+func kindOf(err error) (errorKind, bool) {
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind.err) {
+			return kind, true
+		}
+	}
+
+	return errorKind{}, false
+}
 
-	_ = json.NewEncoder(w).Encode(dto.ErrorResponse{Message: message})
+// codeFromStatus: 404 -> not_found, 500 -> internal_server_error
+func codeFromStatus(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
 }
@@ -1,6 +1,8 @@
 package ws_transport
 
 import (
+	"context"
+	"fmt"
 	"github.com/coder/websocket"
 	"github.com/coder/websocket/wsjson"
 	"github.com/google/uuid"
@@ -11,27 +13,46 @@ import (
 )
 
 var (
-	pause = "pause"
-	play  = "play"
-	next  = "next"
+	pause    = "pause"
+	play     = "play"
+	next     = "next"
+	previous = "previous"
+	repeat   = "repeat"
+
+	move     = "move"
+	playNext = "play_next"
+
+	role = "role"
 )
 
 type ServiceRoom interface {
-	ConnectToTheRoom(id uuid.UUID) (int, <-chan dto.State, error)
+	ConnectToTheRoom(id uuid.UUID, meta dto.Meta) (*dto.Session, <-chan dto.State, error)
 	DisconnectUser(id uuid.UUID, userID int) error
-	AddVideoInQueue(id uuid.UUID, video *dto.Video) error
+	AddVideoInQueue(id uuid.UUID, video *dto.Video, meta dto.Meta) (*dto.QueueEntry, error)
+
+	Play(id uuid.UUID, meta dto.Meta) (dto.State, error)
+	Pause(id uuid.UUID, meta dto.Meta) (dto.State, error)
+	Next(id uuid.UUID, meta dto.Meta) (dto.State, error)
+	Previous(id uuid.UUID, meta dto.Meta) (dto.State, error)
+	SetRepeat(id uuid.UUID, mode string, meta dto.Meta) error
+	MoveVideoInQueue(id uuid.UUID, entryID uuid.UUID, to int, meta dto.Meta) error
+	PlayNext(id uuid.UUID, video *dto.Video, meta dto.Meta) (*dto.QueueEntry, error)
+	SetRole(id uuid.UUID, userID int, role string, meta dto.Meta) error
+	Authorize(id uuid.UUID, meta dto.Meta, role string) error
+}
 
-	Play(id uuid.UUID) error
-	Pause(id uuid.UUID) error
-	Next(id uuid.UUID) error
+// ServiceVideo находит трек по ссылке от клиента, чтобы положить в очередь название и длительность от провайдера
+type ServiceVideo interface {
+	ResolveVideo(ctx context.Context, video *dto.Video) (*dto.Video, error)
 }
 
 type WSHandler struct {
 	service ServiceRoom
+	videos  ServiceVideo
 }
 
-func NewWSHandler(service ServiceRoom) *WSHandler {
-	return &WSHandler{service: service}
+func NewWSHandler(service ServiceRoom, videos ServiceVideo) *WSHandler {
+	return &WSHandler{service: service, videos: videos}
 }
 
 func (h *WSHandler) RoomWS(w http.ResponseWriter, r *http.Request) {
@@ -49,30 +70,49 @@ func (h *WSHandler) RoomWS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, chState, err := h.service.ConnectToTheRoom(id)
+	meta := dto.Meta{
+		Token:    http_transport.TokenFromRequest(r),
+		Password: r.URL.Query().Get("password"),
+	}
+
+	session, chState, err := h.service.ConnectToTheRoom(id, meta)
 	if err != nil {
 		log.Println(err)
-		h.service.DisconnectUser(id, userID)
+		if http_transport.StatusFromError(err) != http.StatusInternalServerError {
+			http_transport.WriteError(w, err)
+			return
+		}
 		http_transport.WriteJsonError(w, http.StatusBadRequest, "room_id is invalid")
 		return
 	}
 
+	userID := session.UserID
+	defer h.service.DisconnectUser(id, userID)
+
 	conn, err := websocket.Accept(w, r, nil)
 	if err != nil {
 		log.Println(err)
+		return
 	}
 
 	defer conn.Close(websocket.StatusNormalClosure, "bye")
-	defer h.service.DisconnectUser(id, userID)
+
+	// первым сообщением сообщаем подключению его айди, роль и токен
+	if err := wsjson.Write(ctx, conn, session); err != nil {
+		log.Println(err)
+		return
+	}
 
 	go func() {
 		for {
 			select {
 			case state, ok := <-chState:
 				if !ok {
+					// подписку сняли (комнату удалили или клиент не успевал читать) — команды от него больше не принимаем
+					conn.Close(websocket.StatusGoingAway, "disconnected from the room")
 					return
 				}
-				if err = wsjson.Write(ctx, conn, state); err != nil {
+				if err := wsjson.Write(ctx, conn, state); err != nil {
 					log.Println(err)
 					return
 				}
@@ -89,15 +129,54 @@ func (h *WSHandler) RoomWS(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		switch cmd.Type {
-		case play:
-			_ = h.service.Play(id)
-		case pause:
-			_ = h.service.Pause(id)
-		case next:
-			_ = h.service.Next(id)
+		if err := h.handleCommand(ctx, id, userID, &cmd); err != nil {
+			// сообщаем клиенту, почему команда не прошла (например, устаревшая версия)
+			if err := wsjson.Write(ctx, conn, http_transport.ErrorResponseFrom(err)); err != nil {
+				log.Println(err)
+				return
+			}
 		}
+	}
 
+}
+
+func (h *WSHandler) handleCommand(ctx context.Context, id uuid.UUID, userID int, cmd *dto.Command) error {
+	meta := dto.Meta{Version: cmd.Version, UserID: userID}
+
+	switch cmd.Type {
+	case play:
+		_, err := h.service.Play(id, meta)
+		return err
+	case pause:
+		_, err := h.service.Pause(id, meta)
+		return err
+	case next:
+		_, err := h.service.Next(id, meta)
+		return err
+	case previous:
+		_, err := h.service.Previous(id, meta)
+		return err
+	case repeat:
+		return h.service.SetRepeat(id, cmd.Mode, meta)
+	case move:
+		return h.service.MoveVideoInQueue(id, cmd.Entry, cmd.To, meta)
+	case playNext:
+		if cmd.Video == nil {
+			return fmt.Errorf("%w: video is required", http_transport.ErrBadRequest)
+		}
+		// слушатель без прав не должен гонять запросы к провайдеру
+		if err := h.service.Authorize(id, meta, dto.RoleDJ); err != nil {
+			return err
+		}
+		video, err := h.videos.ResolveVideo(ctx, cmd.Video)
+		if err != nil {
+			return err
+		}
+		_, err = h.service.PlayNext(id, video, meta)
+		return err
+	case role:
+		return h.service.SetRole(id, cmd.User, cmd.Role, meta)
 	}
 
+	return nil
 }